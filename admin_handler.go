@@ -0,0 +1,125 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type adminHandler struct{}
+
+type adminStatus struct {
+	Loggers  []LoggerLevel  `json:"loggers"`
+	Packages []PackageLevel `json:"packages"`
+}
+
+// Returns a handler to show and change the levels at runtime, it should be served
+// on a local or protected address only:
+//
+//	GET                                           list the loggers and package levels
+//	POST   logger=NAME&allow=DEBUG&deny=OFF       change the levels of a logger
+//	POST   package=PKG&level=TRACE&ttl=10m        set the level of a package, restored after ttl if set
+//	DELETE package=PKG                            remove the level of a package
+func NewAdminHandler() http.Handler {
+	return &adminHandler{}
+}
+
+func (this *adminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var err error
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		err = this.set(r)
+	case http.MethodDelete:
+		packageName := r.FormValue("package")
+		if packageName == "" {
+			err = fmt.Errorf("package is empty")
+		} else {
+			ResetPackageLevel(packageName)
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(adminStatus{
+		Loggers:  GetLevels(),
+		Packages: GetPackageLevels(),
+	})
+}
+
+func (this *adminHandler) set(r *http.Request) error {
+	var (
+		loggerName  = r.FormValue("logger")
+		packageName = r.FormValue("package")
+	)
+
+	if loggerName == "" && packageName == "" {
+		return fmt.Errorf("logger and package are empty")
+	}
+
+	if loggerName != "" {
+		writer := GetWriter(loggerName)
+		if writer == nil {
+			return fmt.Errorf("undefined logger %s", loggerName)
+		}
+
+		allow, deny := writer.Levels()
+
+		for _, v := range []struct {
+			name  string
+			level *LogLevel
+		}{{"allow", &allow}, {"deny", &deny}} {
+			if value := r.FormValue(v.name); value != "" {
+				if !containsString(levelNames, value, false) {
+					return fmt.Errorf("unknown %s level %q", v.name, value)
+				}
+				*v.level = ConvertString2Level(value)
+			}
+		}
+
+		writer.SetLevels(allow, deny)
+	}
+
+	if packageName != "" {
+		value := r.FormValue("level")
+		if !containsString(levelNames, value, false) {
+			return fmt.Errorf("unknown level %q", value)
+		}
+
+		var ttl time.Duration
+		if r.FormValue("ttl") != "" {
+			var err error
+
+			ttl, err = time.ParseDuration(r.FormValue("ttl"))
+			if err != nil || ttl <= 0 {
+				return fmt.Errorf("invalid ttl %q", r.FormValue("ttl"))
+			}
+		}
+
+		SetPackageLevelWithTTL(packageName, ConvertString2Level(value), ttl)
+	}
+
+	return nil
+}
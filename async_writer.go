@@ -0,0 +1,227 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package logger
+
+import (
+	"strings"
+	"sync"
+)
+
+// What to do when the buffer of AsyncWriter is full
+type OverflowPolicy int
+
+const (
+	// Wait until the flusher frees a slot
+	OverflowBlock OverflowPolicy = iota
+	// Drop the new entry
+	OverflowDrop
+	// Drop the entry with the lowest level, the new one if it is the lowest
+	OverflowDropLowestLevel
+
+	DefaultAsyncBufferSize = 8192
+)
+
+func ConvertString2Overflow(str string) OverflowPolicy {
+	switch strings.ToLower(str) {
+	case "drop":
+		return OverflowDrop
+	case "drop-lowest-level":
+		return OverflowDropLowestLevel
+	default:
+		return OverflowBlock
+	}
+}
+
+// AsyncWriter queues log entries in a bounded ring buffer,
+// a background goroutine writes them to output
+type AsyncWriter struct {
+	output   func(entry *Entry) error
+	overflow OverflowPolicy
+
+	mutex   sync.Mutex
+	cond    *sync.Cond
+	entries []*Entry // ring buffer
+	head    int
+	count   int
+	batch   []*Entry // only used by the flusher goroutine
+	writing bool
+	closed  bool
+	dropped uint64
+	failed  uint64
+	done    chan struct{}
+}
+
+func NewAsyncWriter(bufferSize int, overflow OverflowPolicy, output func(entry *Entry) error) *AsyncWriter {
+	if bufferSize <= 0 {
+		bufferSize = DefaultAsyncBufferSize
+	}
+
+	this := &AsyncWriter{
+		output:   output,
+		overflow: overflow,
+		entries:  make([]*Entry, bufferSize),
+		done:     make(chan struct{}),
+	}
+	this.cond = sync.NewCond(&this.mutex)
+
+	go this.run()
+
+	return this
+}
+
+// Enqueue the entry, it is written synchronously after closed
+func (this *AsyncWriter) Write(entry *Entry) error {
+	this.mutex.Lock()
+
+	for !this.closed && this.count == len(this.entries) {
+		switch this.overflow {
+		case OverflowDrop:
+			this.dropped++
+			this.mutex.Unlock()
+			return nil
+		case OverflowDropLowestLevel:
+			if !this.dropLowerLevel(entry.Level) {
+				this.dropped++
+				this.mutex.Unlock()
+				return nil
+			}
+		default:
+			this.cond.Wait()
+		}
+	}
+
+	if this.closed {
+		this.mutex.Unlock()
+		return this.output(entry)
+	}
+
+	this.entries[(this.head+this.count)%len(this.entries)] = entry
+	this.count++
+
+	this.cond.Broadcast()
+	this.mutex.Unlock()
+
+	return nil
+}
+
+// Remove the oldest entry with the lowest level if it is lower than level
+func (this *AsyncWriter) dropLowerLevel(level LogLevel) bool {
+	var (
+		size   = len(this.entries)
+		lowest = -1
+	)
+
+	for i := 0; i < this.count; i++ {
+		entry := this.entries[(this.head+i)%size]
+		if entry.Level < level && (lowest < 0 || entry.Level < this.entries[(this.head+lowest)%size].Level) {
+			lowest = i
+		}
+	}
+
+	if lowest < 0 {
+		return false
+	}
+
+	// shift the following entries forward
+	for i := lowest; i < this.count-1; i++ {
+		this.entries[(this.head+i)%size] = this.entries[(this.head+i+1)%size]
+	}
+	this.count--
+	this.entries[(this.head+this.count)%size] = nil
+	this.dropped++
+
+	return true
+}
+
+func (this *AsyncWriter) run() {
+	defer close(this.done)
+
+	for {
+		this.mutex.Lock()
+		for this.count == 0 && !this.closed {
+			this.cond.Wait()
+		}
+
+		if this.count == 0 && this.closed {
+			this.mutex.Unlock()
+			return
+		}
+
+		this.batch = this.batch[:0]
+		for ; this.count > 0; this.count-- {
+			this.batch = append(this.batch, this.entries[this.head])
+			this.entries[this.head] = nil
+			this.head = (this.head + 1) % len(this.entries)
+		}
+		this.writing = true
+
+		this.cond.Broadcast()
+		this.mutex.Unlock()
+
+		var failed uint64
+		for _, entry := range this.batch {
+			if this.output(entry) != nil {
+				failed++
+			}
+		}
+
+		this.mutex.Lock()
+		this.failed += failed
+		this.writing = false
+		this.cond.Broadcast()
+		this.mutex.Unlock()
+	}
+}
+
+// Wait until all queued entries are written
+func (this *AsyncWriter) Flush() error {
+	this.mutex.Lock()
+	for this.count > 0 || this.writing {
+		this.cond.Wait()
+	}
+	this.mutex.Unlock()
+
+	return nil
+}
+
+// Write the queued entries and stop the flusher goroutine
+func (this *AsyncWriter) Close() error {
+	this.mutex.Lock()
+	if this.closed {
+		this.mutex.Unlock()
+		return nil
+	}
+	this.closed = true
+	this.cond.Broadcast()
+	this.mutex.Unlock()
+
+	<-this.done
+
+	return nil
+}
+
+// The count of entries dropped because the buffer is full
+func (this *AsyncWriter) Dropped() uint64 {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	return this.dropped
+}
+
+// The count of entries failed to write to output
+func (this *AsyncWriter) Failed() uint64 {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	return this.failed
+}
@@ -0,0 +1,85 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestAsyncWriter(t *testing.T) {
+	var (
+		mutex    sync.Mutex
+		messages []string
+		block    = make(chan struct{})
+		started  = make(chan struct{}, 1)
+	)
+
+	writer := NewAsyncWriter(2, OverflowDropLowestLevel, func(entry *Entry) error {
+		select {
+		case started <- struct{}{}:
+			<-block
+		default:
+		}
+
+		mutex.Lock()
+		messages = append(messages, entry.Message)
+		mutex.Unlock()
+
+		return nil
+	})
+
+	// the first entry blocks the flusher goroutine
+	writer.Write(&Entry{Level: INFO, Message: "info 1"})
+	<-started
+
+	writer.Write(&Entry{Level: DEBUG, Message: "debug 2"})
+	writer.Write(&Entry{Level: WARN, Message: "warn 3"})
+	writer.Write(&Entry{Level: ERROR, Message: "error 4"}) // drop debug 2
+	writer.Write(&Entry{Level: TRACE, Message: "trace 5"}) // drop itself
+
+	close(block)
+	writer.Close()
+
+	if writer.Dropped() != 2 {
+		t.Errorf("unexpected dropped count %d", writer.Dropped())
+	}
+
+	if result := strings.Join(messages, ","); result != "info 1,warn 3,error 4" {
+		t.Errorf("unexpected messages %s", result)
+	}
+}
+
+func TestAsyncLoggerWriter(t *testing.T) {
+	var buf bytes.Buffer
+
+	writer := NewLoggerWriter(&buf, ALL)
+	writer.closeFilter = true
+	writer.SetFormatter(NewTextFormatterWithFormat("%{Message}"))
+	writer.SetAsync(16, OverflowBlock)
+
+	for i := 0; i < 100; i++ {
+		writer.Infof("message %d", i)
+	}
+	writer.Flush()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 100 || lines[99] != fmt.Sprintf("message %d", 99) {
+		t.Errorf("unexpected lines %d", len(lines))
+	}
+
+	writer.Close()
+}
@@ -0,0 +1,201 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package logger
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultBatchSize     = 100
+	DefaultFlushInterval = 1000 // ms
+)
+
+// the pending entries are limited to batchSize * maxPendingBatches
+var maxPendingBatches = 10
+
+// BatchWriter collects entries and writes them to output in batches by a background goroutine,
+// a batch is written when it is full, an entry of the flush level is added or every flush interval
+type BatchWriter struct {
+	output        func(batch []*Entry) error
+	batchSize     int
+	flushInterval time.Duration
+	flushLevel    LogLevel
+
+	mutex   sync.Mutex // guard entries and options
+	entries []*Entry
+	sending sync.Mutex // keep the order of batches
+	notify  chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+	closed  bool
+}
+
+func NewBatchWriter(batchSize int, flushInterval time.Duration, output func(batch []*Entry) error) *BatchWriter {
+	this := &BatchWriter{
+		output:     output,
+		flushLevel: OFF,
+		notify:     make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	this.SetBatch(batchSize, flushInterval)
+
+	go this.run()
+
+	return this
+}
+
+// Set the max entries of a batch and the interval of writing the batch not full,
+// the default value is used if it is not positive
+func (this *BatchWriter) SetBatch(batchSize int, flushInterval time.Duration) {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	if flushInterval <= 0 {
+		flushInterval = DefaultFlushInterval * time.Millisecond
+	}
+
+	this.mutex.Lock()
+	this.batchSize = batchSize
+	this.flushInterval = flushInterval
+	this.mutex.Unlock()
+
+	// restart the timer
+	this.wakeup()
+}
+
+// Write the pending batch without waiting for the interval when an entry of level or higher is added,
+// OFF disables it
+func (this *BatchWriter) SetFlushLevel(level LogLevel) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.flushLevel = level
+}
+
+// Add the entry to the pending batch
+func (this *BatchWriter) Write(entry *Entry) error {
+	this.mutex.Lock()
+
+	if this.closed {
+		this.mutex.Unlock()
+		return errors.New("batch writer is closed")
+	}
+
+	if len(this.entries) >= this.batchSize*maxPendingBatches {
+		this.mutex.Unlock()
+		return errors.New("batch writer is full")
+	}
+
+	this.entries = append(this.entries, entry)
+	full := len(this.entries) >= this.batchSize || entry.Level >= this.flushLevel
+
+	this.mutex.Unlock()
+
+	if full {
+		this.wakeup()
+	}
+
+	return nil
+}
+
+// Write the pending entries
+func (this *BatchWriter) Flush() error {
+	return this.send()
+}
+
+// Write the pending entries and stop the background goroutine
+func (this *BatchWriter) Close() error {
+	this.mutex.Lock()
+	if this.closed {
+		this.mutex.Unlock()
+		return nil
+	}
+	this.closed = true
+	this.mutex.Unlock()
+
+	close(this.stop)
+	<-this.done
+
+	return this.send()
+}
+
+// Closed when the writer is closing, output can stop waiting for retry
+func (this *BatchWriter) Closing() <-chan struct{} {
+	return this.stop
+}
+
+func (this *BatchWriter) wakeup() {
+	select {
+	case this.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (this *BatchWriter) run() {
+	defer close(this.done)
+
+	for {
+		this.mutex.Lock()
+		interval := this.flushInterval
+		this.mutex.Unlock()
+
+		timer := time.NewTimer(interval)
+
+		select {
+		case <-this.stop:
+			timer.Stop()
+			return
+		case <-this.notify:
+			timer.Stop()
+		case <-timer.C:
+		}
+
+		err := this.send()
+		if err != nil {
+			DefaultConsoleLogger().Errorf("write log batch error: %s", err.Error())
+		}
+	}
+}
+
+// Write the pending entries in batches
+func (this *BatchWriter) send() error {
+	this.sending.Lock()
+	defer this.sending.Unlock()
+
+	var errs MultiError
+
+	for {
+		this.mutex.Lock()
+		size := len(this.entries)
+		if size > this.batchSize {
+			size = this.batchSize
+		}
+		batch := this.entries[:size:size]
+		this.entries = this.entries[size:]
+		this.mutex.Unlock()
+
+		if len(batch) == 0 {
+			return errs.ErrorOrNil()
+		}
+
+		err := this.output(batch)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+}
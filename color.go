@@ -0,0 +1,106 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package logger
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Whether %{Color} of TextFormatter is rendered
+const (
+	ColorAuto   = "auto"   // colored if the output of the logger is a terminal and NO_COLOR is not set
+	ColorAlways = "always" // colored even if the output is not a terminal, such as for CI
+	ColorNever  = "never"
+
+	colorReset = "\033[0m"
+)
+
+var (
+	colorModes = []string{"", ColorAuto, ColorAlways, ColorNever}
+
+	// the ANSI codes of the colors
+	colorCodes = map[string]string{
+		"black":   "30",
+		"red":     "31",
+		"green":   "32",
+		"yellow":  "33",
+		"blue":    "34",
+		"magenta": "35",
+		"cyan":    "36",
+		"white":   "37",
+		"gray":    "90",
+	}
+
+	// the colors of the levels used by ConsoleLogger before, ALL is used by Print and Printf
+	defaultLevelColors = map[LogLevel]string{
+		ALL:   "white",
+		TRACE: "blue",
+		DEBUG: "green",
+		INFO:  "cyan",
+		WARN:  "magenta",
+		ERROR: "yellow",
+		FATAL: "red",
+	}
+)
+
+// Returns the escape sequence of the color and bold, color is empty or a name such as red
+func colorSequence(color string, bold bool) (string, error) {
+	var codes []string
+	if bold {
+		codes = append(codes, "1")
+	}
+
+	if color != "" {
+		code, ok := colorCodes[strings.ToLower(color)]
+		if !ok {
+			return "", fmt.Errorf("unknown color %q", color)
+		}
+
+		codes = append(codes, code)
+	}
+
+	if len(codes) == 0 {
+		return "", nil
+	}
+
+	return "\033[" + strings.Join(codes, ";") + "m", nil
+}
+
+// Returns whether the colors are rendered in mode to w,
+// in ColorAuto mode only a terminal is colored
+func colorEnabled(mode string, w io.Writer) bool {
+	switch strings.ToLower(mode) {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+
+	// https://no-color.org
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+
+	f, ok := w.(*os.File)
+
+	return ok && isTerminal(f)
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
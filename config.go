@@ -13,76 +13,260 @@
 package logger
 
 import (
+	"encoding/json"
 	"encoding/xml"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
 	"os"
+	"path"
+	"strings"
+)
+
+// Supported config file formats
+const (
+	ConfigFormatXML  = "xml"
+	ConfigFormatYAML = "yaml"
+	ConfigFormatJSON = "json"
+	ConfigFormatTOML = "toml"
 )
 
 type Config struct {
-	XMLName         xml.Name   `xml:"Configuration"`
-	RollingInterval int        `xml:"rollingInterval,attr"`
-	Properties      []Property `xml:"Properties>Property"`
-	Loggers         []Logger   `xml:"Loggers>Logger"`
-	DefaultFilter   Filter     `xml:"Filters>DefaultFilter>Filter"`
-	PackageFilters  []Filter   `xml:"Filters>PackageFilter>Filter"`
+	XMLName         xml.Name   `xml:"Configuration" yaml:"-" json:"-" toml:"-"`
+	RollingInterval int        `xml:"rollingInterval,attr" yaml:"rollingInterval" json:"rollingInterval" toml:"rollingInterval"`
+	WatchInterval   int        `xml:"watchInterval,attr" yaml:"watchInterval" json:"watchInterval" toml:"watchInterval"`
+	Strict          bool       `xml:"strict,attr" yaml:"strict" json:"strict" toml:"strict"`
+	Properties      []Property `xml:"Properties>Property" yaml:"properties" json:"properties" toml:"properties"`
+	Loggers         []Logger   `xml:"Loggers>Logger" yaml:"loggers" json:"loggers" toml:"loggers"`
+	DefaultFilter   Filter     `xml:"Filters>DefaultFilter>Filter" yaml:"defaultFilter" json:"defaultFilter" toml:"defaultFilter"`
+	PackageFilters  []Filter   `xml:"Filters>PackageFilter>Filter" yaml:"packageFilters" json:"packageFilters" toml:"packageFilters"`
+
+	positions map[string]Position // positions of xml elements, used by Validate
 }
 
 type Property struct {
-	XMLName xml.Name `xml:"Property"`
-	Name    string   `xml:"name,attr"`
-	Value   string   `xml:",innerxml"`
+	XMLName xml.Name `xml:"Property" yaml:"-" json:"-" toml:"-"`
+	Name    string   `xml:"name,attr" yaml:"name" json:"name" toml:"name"`
+	Value   string   `xml:",innerxml" yaml:"value" json:"value" toml:"value"`
 }
 
 type Logger struct {
-	XMLName     xml.Name `xml:"Logger"`
-	Name        string   `xml:"name,attr"`
-	Target      string   `xml:"target,attr"`
-	FileName    string   `xml:"fileName,attr"`
-	FilePattern string   `xml:"filePattern,attr"`
-	Compress    string   `xml:"compress,attr"`
-	Format      Format   `xml:"Format"`
-	Level       Level    `xml:"Level"`
-	Rolling     Rolling  `xml:"Rolling"`
+	XMLName       xml.Name   `xml:"Logger" yaml:"-" json:"-" toml:"-"`
+	Name          string     `xml:"name,attr" yaml:"name" json:"name" toml:"name"`
+	Target        string     `xml:"target,attr" yaml:"target" json:"target" toml:"target"`
+	FileName      string     `xml:"fileName,attr" yaml:"fileName" json:"fileName" toml:"fileName"`
+	FilePattern   string     `xml:"filePattern,attr" yaml:"filePattern" json:"filePattern" toml:"filePattern"`
+	Compress      string     `xml:"compress,attr" yaml:"compress" json:"compress" toml:"compress"`
+	Async         bool       `xml:"async,attr" yaml:"async" json:"async" toml:"async"`
+	BufferSize    int        `xml:"bufferSize,attr" yaml:"bufferSize" json:"bufferSize" toml:"bufferSize"`
+	Overflow      string     `xml:"overflow,attr" yaml:"overflow" json:"overflow" toml:"overflow"`
+	Network       string     `xml:"network,attr" yaml:"network" json:"network" toml:"network"`
+	Address       string     `xml:"address,attr" yaml:"address" json:"address" toml:"address"`
+	Facility      string     `xml:"facility,attr" yaml:"facility" json:"facility" toml:"facility"`
+	AppName       string     `xml:"appName,attr" yaml:"appName" json:"appName" toml:"appName"`
+	SyslogFormat  string     `xml:"syslogFormat,attr" yaml:"syslogFormat" json:"syslogFormat" toml:"syslogFormat"`
+	Framing       string     `xml:"framing,attr" yaml:"framing" json:"framing" toml:"framing"`
+	SpoolFile     string     `xml:"spoolFile,attr" yaml:"spoolFile" json:"spoolFile" toml:"spoolFile"`
+	SpoolSize     int        `xml:"spoolSize,attr" yaml:"spoolSize" json:"spoolSize" toml:"spoolSize"`
+	URL           string     `xml:"url,attr" yaml:"url" json:"url" toml:"url"`
+	BatchFormat   string     `xml:"batchFormat,attr" yaml:"batchFormat" json:"batchFormat" toml:"batchFormat"`
+	BatchSize     int        `xml:"batchSize,attr" yaml:"batchSize" json:"batchSize" toml:"batchSize"`
+	FlushInterval int        `xml:"flushInterval,attr" yaml:"flushInterval" json:"flushInterval" toml:"flushInterval"`
+	FlushLevel    string     `xml:"flushLevel,attr" yaml:"flushLevel" json:"flushLevel" toml:"flushLevel"`
+	Retries       int        `xml:"retries,attr" yaml:"retries" json:"retries" toml:"retries"`
+	Headers       []Header   `xml:"Headers>Header" yaml:"headers" json:"headers" toml:"headers"`
+	Driver        string     `xml:"driver,attr" yaml:"driver" json:"driver" toml:"driver"`
+	DataSource    string     `xml:"dataSource,attr" yaml:"dataSource" json:"dataSource" toml:"dataSource"`
+	Table         string     `xml:"table,attr" yaml:"table" json:"table" toml:"table"`
+	Retention     int        `xml:"retention,attr" yaml:"retention" json:"retention" toml:"retention"`
+	Failover      bool       `xml:"failover,attr" yaml:"failover" json:"failover" toml:"failover"`
+	Appenders     []Appender `xml:"Appenders>Appender" yaml:"appenders" json:"appenders" toml:"appenders"`
+	Capacity      int        `xml:"capacity,attr" yaml:"capacity" json:"capacity" toml:"capacity"`
+	DumpTo        string     `xml:"dumpTo,attr" yaml:"dumpTo" json:"dumpTo" toml:"dumpTo"`
+	DumpLevel     string     `xml:"dumpLevel,attr" yaml:"dumpLevel" json:"dumpLevel" toml:"dumpLevel"`
+	Format        Format     `xml:"Format" yaml:"format" json:"format" toml:"format"`
+	Colors        []Color    `xml:"Colors>Color" yaml:"colors" json:"colors" toml:"colors"`
+	Level         Level      `xml:"Level" yaml:"level" json:"level" toml:"level"`
+	Rolling       Rolling    `xml:"Rolling" yaml:"rolling" json:"rolling" toml:"rolling"`
+	Sampling      Sampling   `xml:"Sampling" yaml:"sampling" json:"sampling" toml:"sampling"`
+	Dedup         Dedup      `xml:"Dedup" yaml:"dedup" json:"dedup" toml:"dedup"`
+}
+
+type Header struct {
+	XMLName xml.Name `xml:"Header" yaml:"-" json:"-" toml:"-"`
+	Name    string   `xml:"name,attr" yaml:"name" json:"name" toml:"name"`
+	Value   string   `xml:",innerxml" yaml:"value" json:"value" toml:"value"`
+}
+
+// A logger referenced by the MULTI logger, the level overrides the level of the logger if set
+type Appender struct {
+	XMLName xml.Name `xml:"Appender" yaml:"-" json:"-" toml:"-"`
+	Name    string   `xml:"name,attr" yaml:"name" json:"name" toml:"name"`
+	Level   Level    `xml:"Level" yaml:"level" json:"level" toml:"level"`
 }
 
 type Format struct {
-	XMLName xml.Name `xml:"Format"`
-	Type    string   `xml:"type,attr"`
-	Value   string   `xml:",innerxml"`
+	XMLName xml.Name `xml:"Format" yaml:"-" json:"-" toml:"-"`
+	Type    string   `xml:"type,attr" yaml:"type" json:"type" toml:"type"`
+	Color   string   `xml:"color,attr" yaml:"color" json:"color" toml:"color"` // auto, always or never
+	Value   string   `xml:",innerxml" yaml:"value" json:"value" toml:"value"`
+
+	// The schema of the json format
+	Indent     bool   `xml:"indent,attr" yaml:"indent" json:"indent" toml:"indent"`
+	TimeFormat string `xml:"timeFormat,attr" yaml:"timeFormat" json:"timeFormat" toml:"timeFormat"` // rfc3339, rfc3339nano, millis, nanos or a layout
+	LevelCase  string `xml:"levelCase,attr" yaml:"levelCase" json:"levelCase" toml:"levelCase"`     // upper or lower
+	Caller     string `xml:"caller,attr" yaml:"caller" json:"caller" toml:"caller"`                 // flat or nested
+	Keys       string `xml:"keys,attr" yaml:"keys" json:"keys" toml:"keys"`                         // renamed keys like Message=msg,Prefix=-
+	Extra      string `xml:"extra,attr" yaml:"extra" json:"extra" toml:"extra"`                     // static fields like service=api
+}
+
+// The style of %{Color} for a level, overrides the default color of the level
+type Color struct {
+	XMLName xml.Name `xml:"Color" yaml:"-" json:"-" toml:"-"`
+	Level   string   `xml:"level,attr" yaml:"level" json:"level" toml:"level"`
+	Color   string   `xml:"color,attr" yaml:"color" json:"color" toml:"color"`
+	Bold    bool     `xml:"bold,attr" yaml:"bold" json:"bold" toml:"bold"`
 }
 
 type Level struct {
-	XMLName xml.Name `xml:"Level"`
-	Allow   string   `xml:"Allow"`
-	Deny    string   `xml:"Deny"`
+	XMLName xml.Name `xml:"Level" yaml:"-" json:"-" toml:"-"`
+	Allow   string   `xml:"Allow" yaml:"allow" json:"allow" toml:"allow"`
+	Deny    string   `xml:"Deny" yaml:"deny" json:"deny" toml:"deny"`
 }
 
 type Rolling struct {
-	XMLName   xml.Name `xml:"Rolling"`
-	TimeBased string   `xml:"TimeBased"`
-	SizeBased int      `xml:"SizeBased"`
-	KeepCount int      `xml:"KeepCount"`
+	XMLName   xml.Name `xml:"Rolling" yaml:"-" json:"-" toml:"-"`
+	TimeBased string   `xml:"TimeBased" yaml:"timeBased" json:"timeBased" toml:"timeBased"`
+	SizeBased int      `xml:"SizeBased" yaml:"sizeBased" json:"sizeBased" toml:"sizeBased"`
+	KeepCount int      `xml:"KeepCount" yaml:"keepCount" json:"keepCount" toml:"keepCount"`
 }
 
+// Sampling of the entries of every level and call site,
+// intervals are in ms
+type Sampling struct {
+	XMLName         xml.Name `xml:"Sampling" yaml:"-" json:"-" toml:"-"`
+	Type            string   `xml:"type,attr" yaml:"type" json:"type" toml:"type"`
+	Interval        int      `xml:"interval,attr" yaml:"interval" json:"interval" toml:"interval"`
+	First           int      `xml:"first,attr" yaml:"first" json:"first" toml:"first"`
+	Thereafter      int      `xml:"thereafter,attr" yaml:"thereafter" json:"thereafter" toml:"thereafter"`
+	Rate            float64  `xml:"rate,attr" yaml:"rate" json:"rate" toml:"rate"`
+	Burst           int      `xml:"burst,attr" yaml:"burst" json:"burst" toml:"burst"`
+	SummaryInterval int      `xml:"summaryInterval,attr" yaml:"summaryInterval" json:"summaryInterval" toml:"summaryInterval"`
+}
+
+// Deduplication of the consecutive identical entries within window ms,
+// enabled if window is positive
+type Dedup struct {
+	XMLName xml.Name `xml:"Dedup" yaml:"-" json:"-" toml:"-"`
+	Window  int      `xml:"window,attr" yaml:"window" json:"window" toml:"window"`
+}
+
+// A package filter matches the package named and its sub packages, the segments of
+// the name may be glob patterns. The entries below level are dropped, and the
+// DefaultFilter is not applied if additivity is false
 type Filter struct {
-	XMLName xml.Name `xml:"Filter"`
-	Name    string   `xml:"name,attr"`
-	Loggers []string `xml:"Logger"`
+	XMLName    xml.Name `xml:"Filter" yaml:"-" json:"-" toml:"-"`
+	Name       string   `xml:"name,attr" yaml:"name" json:"name" toml:"name"`
+	Level      string   `xml:"level,attr" yaml:"level" json:"level" toml:"level"`
+	Additivity *bool    `xml:"additivity,attr" yaml:"additivity" json:"additivity" toml:"additivity"`
+	Loggers    []string `xml:"Logger" yaml:"loggers" json:"loggers" toml:"loggers"`
 }
 
+// Returns whether the DefaultFilter is applied with this filter, true if not set
+func (this Filter) Additive() bool {
+	return this.Additivity == nil || *this.Additivity
+}
+
+// Returns the names of the loggers referenced by this logger, they are created before it
+func (this *Logger) references() []string {
+	var names []string
+
+	switch this.Target {
+	case "MULTI":
+		for _, appender := range this.Appenders {
+			names = append(names, appender.Name)
+		}
+	case "MEMORY":
+		if this.DumpTo != "" {
+			names = append(names, this.DumpTo)
+		}
+	}
+
+	return names
+}
+
+// Parse the config file, the format is selected by the file extension,
+// .yaml/.yml, .json and .toml are supported, others are parsed as xml
 func NewConfig(configFile string) (*Config, error) {
+	return NewConfigWithFormat(configFile, ConfigFormatByExt(configFile))
+}
+
+func NewConfigWithFormat(configFile, format string) (*Config, error) {
 	file, err := os.OpenFile(configFile, os.O_RDONLY, 0)
 	if err != nil {
 		DefaultConsoleLogger().Errorf("error: Open config file %v", err)
 		return nil, err
 	}
+	defer file.Close()
 
 	config := &Config{}
-	decoder := xml.NewDecoder(file)
-	err = decoder.Decode(config)
+
+	switch strings.ToLower(format) {
+	case ConfigFormatXML:
+		var data []byte
+		data, err = ioutil.ReadAll(file)
+		if err == nil {
+			err = xml.Unmarshal(data, config)
+			config.positions = xmlPositions(data)
+		}
+	case ConfigFormatYAML:
+		err = yaml.NewDecoder(file).Decode(config)
+	case ConfigFormatJSON:
+		err = json.NewDecoder(file).Decode(config)
+	case ConfigFormatTOML:
+		_, err = toml.DecodeReader(file, config)
+	default:
+		err = fmt.Errorf("unsupported config format %s", format)
+	}
+
 	if err != nil {
-		DefaultConsoleLogger().Errorf("error: Decode xml %v", err)
+		DefaultConsoleLogger().Errorf("error: Decode %s %v", format, err)
 		return nil, err
 	}
 
 	return config, nil
 }
+
+// Returns the config format of the file extension, default is xml
+func ConfigFormatByExt(configFile string) string {
+	switch strings.ToLower(path.Ext(configFile)) {
+	case ".yaml", ".yml":
+		return ConfigFormatYAML
+	case ".json":
+		return ConfigFormatJSON
+	case ".toml":
+		return ConfigFormatTOML
+	default:
+		return ConfigFormatXML
+	}
+}
+
+// Validate the config, the problems fail the initialization in strict mode,
+// otherwise they are printed as warnings
+func validateConfig(c *Config) error {
+	err := c.Validate()
+	if err == nil {
+		return nil
+	}
+
+	if c.Strict {
+		return err
+	}
+
+	for _, e := range err.(MultiError) {
+		DefaultConsoleLogger().Warnf("config: %s", e.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,346 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package logger
+
+import (
+	"fmt"
+)
+
+// ConfigBuilder builds a Config in code instead of a config file,
+// the logger options apply to the logger added last
+type ConfigBuilder struct {
+	config Config
+	errs   MultiError
+}
+
+func NewConfigBuilder() *ConfigBuilder {
+	return &ConfigBuilder{}
+}
+
+func (this *ConfigBuilder) RollingInterval(seconds int) *ConfigBuilder {
+	this.config.RollingInterval = seconds
+
+	return this
+}
+
+// Fail the initialization if the config is invalid
+func (this *ConfigBuilder) Strict(strict bool) *ConfigBuilder {
+	this.config.Strict = strict
+
+	return this
+}
+
+func (this *ConfigBuilder) Property(name, value string) *ConfigBuilder {
+	this.config.Properties = append(this.config.Properties, Property{Name: name, Value: value})
+
+	return this
+}
+
+// Add a logger of any target
+func (this *ConfigBuilder) Logger(v Logger) *ConfigBuilder {
+	this.config.Loggers = append(this.config.Loggers, v)
+
+	return this
+}
+
+// Add a logger writes to stdout
+func (this *ConfigBuilder) ConsoleLogger(name string) *ConfigBuilder {
+	return this.Logger(Logger{Name: name, Target: "STDOUT"})
+}
+
+// Add a logger writes to fileName and rolling to filePattern
+func (this *ConfigBuilder) FileLogger(name, fileName, filePattern string) *ConfigBuilder {
+	return this.Logger(Logger{Name: name, Target: "FILE", FileName: fileName, FilePattern: filePattern})
+}
+
+// Add a logger writes to syslog, the local syslog is used if network and address are empty
+func (this *ConfigBuilder) SyslogLogger(name, network, address string) *ConfigBuilder {
+	return this.Logger(Logger{Name: name, Target: "SYSLOG", Network: network, Address: address})
+}
+
+// Add a logger streams to the collector at address over tcp, tls or udp
+func (this *ConfigBuilder) NetworkLogger(name, network, address string) *ConfigBuilder {
+	return this.Logger(Logger{Name: name, Target: "NETWORK", Network: network, Address: address})
+}
+
+// Add a logger posts the entries to url in batches
+func (this *ConfigBuilder) HTTPLogger(name, url string) *ConfigBuilder {
+	return this.Logger(Logger{Name: name, Target: "HTTP", URL: url})
+}
+
+// Add a logger inserts the entries into table, the driver must be imported by the application
+func (this *ConfigBuilder) DatabaseLogger(name, driver, dataSource, table string) *ConfigBuilder {
+	return this.Logger(Logger{Name: name, Target: "DATABASE", Driver: driver, DataSource: dataSource, Table: table})
+}
+
+// Add a logger fans out to the appenders, with failover only the first one succeeds is written
+func (this *ConfigBuilder) MultiLogger(name string, failover bool) *ConfigBuilder {
+	return this.Logger(Logger{Name: name, Target: "MULTI", Failover: failover})
+}
+
+// Add a logger keeps the last capacity entries in memory
+func (this *ConfigBuilder) MemoryLogger(name string, capacity int) *ConfigBuilder {
+	return this.Logger(Logger{Name: name, Target: "MEMORY", Capacity: capacity})
+}
+
+func (this *ConfigBuilder) Level(allow, deny string) *ConfigBuilder {
+	if v := this.current("Level"); v != nil {
+		v.Level.Allow = allow
+		v.Level.Deny = deny
+	}
+
+	return this
+}
+
+func (this *ConfigBuilder) Format(formatType, format string) *ConfigBuilder {
+	if v := this.current("Format"); v != nil {
+		v.Format.Type = formatType
+		v.Format.Value = format
+	}
+
+	return this
+}
+
+// Set the json format with the schema of format, such as TimeFormat, Keys and Extra
+func (this *ConfigBuilder) JSONFormat(format Format) *ConfigBuilder {
+	if v := this.current("JSONFormat"); v != nil {
+		format.XMLName = v.Format.XMLName
+		format.Type = "json"
+		format.Color = v.Format.Color
+		v.Format = format
+	}
+
+	return this
+}
+
+func (this *ConfigBuilder) Rolling(timeBased string, sizeBased, keepCount int) *ConfigBuilder {
+	if v := this.current("Rolling"); v != nil {
+		v.Rolling.TimeBased = timeBased
+		v.Rolling.SizeBased = sizeBased
+		v.Rolling.KeepCount = keepCount
+	}
+
+	return this
+}
+
+func (this *ConfigBuilder) Compress(compress string) *ConfigBuilder {
+	if v := this.current("Compress"); v != nil {
+		v.Compress = compress
+	}
+
+	return this
+}
+
+func (this *ConfigBuilder) Async(bufferSize int, overflow string) *ConfigBuilder {
+	if v := this.current("Async"); v != nil {
+		v.Async = true
+		v.BufferSize = bufferSize
+		v.Overflow = overflow
+	}
+
+	return this
+}
+
+func (this *ConfigBuilder) Syslog(facility, appName, syslogFormat string) *ConfigBuilder {
+	if v := this.current("Syslog"); v != nil {
+		v.Facility = facility
+		v.AppName = appName
+		v.SyslogFormat = syslogFormat
+	}
+
+	return this
+}
+
+// Set the framing of the network logger, newline or length
+func (this *ConfigBuilder) Framing(framing string) *ConfigBuilder {
+	if v := this.current("Framing"); v != nil {
+		v.Framing = framing
+	}
+
+	return this
+}
+
+// Spool the entries of the network logger to spoolFile while disconnected, spoolSize is in MB
+func (this *ConfigBuilder) Spool(spoolFile string, spoolSize int) *ConfigBuilder {
+	if v := this.current("Spool"); v != nil {
+		v.SpoolFile = spoolFile
+		v.SpoolSize = spoolSize
+	}
+
+	return this
+}
+
+// Add a request header of the http logger
+func (this *ConfigBuilder) Header(name, value string) *ConfigBuilder {
+	if v := this.current("Header"); v != nil {
+		v.Headers = append(v.Headers, Header{Name: name, Value: value})
+	}
+
+	return this
+}
+
+// Set the body format, max entries and flush interval in ms of the http logger batches
+func (this *ConfigBuilder) Batch(batchFormat string, batchSize, flushInterval int) *ConfigBuilder {
+	if v := this.current("Batch"); v != nil {
+		v.BatchFormat = batchFormat
+		v.BatchSize = batchSize
+		v.FlushInterval = flushInterval
+	}
+
+	return this
+}
+
+// Write the pending batch immediately when an entry of level or higher is written
+func (this *ConfigBuilder) FlushLevel(level string) *ConfigBuilder {
+	if v := this.current("FlushLevel"); v != nil {
+		v.FlushLevel = level
+	}
+
+	return this
+}
+
+// Set the days to keep the entries of the database logger, purged by the time based rolling
+func (this *ConfigBuilder) Retention(days int) *ConfigBuilder {
+	if v := this.current("Retention"); v != nil {
+		v.Retention = days
+	}
+
+	return this
+}
+
+// Add an appender to the multi logger, the levels override the levels of the appender if not empty
+func (this *ConfigBuilder) Appender(name, allow, deny string) *ConfigBuilder {
+	if v := this.current("Appender"); v != nil {
+		v.Appenders = append(v.Appenders, Appender{Name: name, Level: Level{Allow: allow, Deny: deny}})
+	}
+
+	return this
+}
+
+// Dump the entries of the memory logger to the logger dumpTo when an entry of dumpLevel or higher is written
+func (this *ConfigBuilder) Dump(dumpTo, dumpLevel string) *ConfigBuilder {
+	if v := this.current("Dump"); v != nil {
+		v.DumpTo = dumpTo
+		v.DumpLevel = dumpLevel
+	}
+
+	return this
+}
+
+// Set whether %{Color} of the text format is rendered, auto, always or never, and the styles of levels
+func (this *ConfigBuilder) Color(mode string, colors ...Color) *ConfigBuilder {
+	if v := this.current("Color"); v != nil {
+		v.Format.Color = mode
+		v.Colors = append(v.Colors, colors...)
+	}
+
+	return this
+}
+
+// Limit the entries of every level and call site
+func (this *ConfigBuilder) Sampling(sampling Sampling) *ConfigBuilder {
+	if v := this.current("Sampling"); v != nil {
+		v.Sampling = sampling
+	}
+
+	return this
+}
+
+// Collapse the consecutive identical entries within window ms
+func (this *ConfigBuilder) Dedup(window int) *ConfigBuilder {
+	if v := this.current("Dedup"); v != nil {
+		v.Dedup.Window = window
+	}
+
+	return this
+}
+
+// Set the loggers used by all packages
+func (this *ConfigBuilder) DefaultFilter(loggers ...string) *ConfigBuilder {
+	this.config.DefaultFilter.Loggers = append(this.config.DefaultFilter.Loggers, loggers...)
+
+	return this
+}
+
+// Set the loggers used by the package
+func (this *ConfigBuilder) PackageFilter(packageName string, loggers ...string) *ConfigBuilder {
+	this.config.PackageFilters = append(this.config.PackageFilters, Filter{Name: packageName, Loggers: loggers})
+
+	return this
+}
+
+// Add a package filter of any options, such as the level and additivity
+func (this *ConfigBuilder) Filter(v Filter) *ConfigBuilder {
+	this.config.PackageFilters = append(this.config.PackageFilters, v)
+
+	return this
+}
+
+// Returns the built Config, or the errors of building and validating
+func (this *ConfigBuilder) Build() (*Config, error) {
+	// the builder may be used again, the returned config must not share its slices
+	c := this.config.clone()
+
+	errs := append(MultiError{}, this.errs...)
+	if err := c.Validate(); err != nil {
+		errs = append(errs, err.(MultiError)...)
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	return &c, nil
+}
+
+// Returns a deep copy of the config
+func (this Config) clone() Config {
+	c := this
+	c.Properties = append([]Property(nil), this.Properties...)
+	c.DefaultFilter = this.DefaultFilter.clone()
+
+	c.Loggers = nil
+	for _, v := range this.Loggers {
+		v.Headers = append([]Header(nil), v.Headers...)
+		v.Appenders = append([]Appender(nil), v.Appenders...)
+		v.Colors = append([]Color(nil), v.Colors...)
+		c.Loggers = append(c.Loggers, v)
+	}
+
+	c.PackageFilters = nil
+	for _, filter := range this.PackageFilters {
+		c.PackageFilters = append(c.PackageFilters, filter.clone())
+	}
+
+	return c
+}
+
+func (this Filter) clone() Filter {
+	filter := this
+	filter.Loggers = append([]string(nil), this.Loggers...)
+	if this.Additivity != nil {
+		additivity := *this.Additivity
+		filter.Additivity = &additivity
+	}
+
+	return filter
+}
+
+func (this *ConfigBuilder) current(option string) *Logger {
+	if len(this.config.Loggers) == 0 {
+		this.errs = append(this.errs, fmt.Errorf("%s: no logger defined", option))
+		return nil
+	}
+
+	return &this.config.Loggers[len(this.config.Loggers)-1]
+}
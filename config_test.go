@@ -0,0 +1,261 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package logger
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Clear the xml names and the white spaces of xml inner values
+func normalizeConfig(c *Config) *Config {
+	c.XMLName = xml.Name{}
+	c.positions = nil
+	for i := range c.Properties {
+		c.Properties[i].XMLName = xml.Name{}
+		c.Properties[i].Value = RemoveEnterAndSpace(c.Properties[i].Value)
+	}
+
+	for i := range c.Loggers {
+		v := &c.Loggers[i]
+		v.XMLName, v.Format.XMLName, v.Level.XMLName, v.Rolling.XMLName = xml.Name{}, xml.Name{}, xml.Name{}, xml.Name{}
+		v.Format.Value = RemoveEnterAndSpace(v.Format.Value)
+		for j := range v.Colors {
+			v.Colors[j].XMLName = xml.Name{}
+		}
+	}
+
+	c.DefaultFilter.XMLName = xml.Name{}
+	for i := range c.PackageFilters {
+		c.PackageFilters[i].XMLName = xml.Name{}
+	}
+
+	return c
+}
+
+func TestConfigFormats(t *testing.T) {
+	expected, err := NewConfig("example/logger.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	normalizeConfig(expected)
+
+	for _, file := range []string{"example/logger.yaml", "example/logger.json", "example/logger.toml"} {
+		c, err := NewConfig(file)
+		if err != nil {
+			t.Fatalf("%s: %s", file, err.Error())
+		}
+
+		if !reflect.DeepEqual(expected, normalizeConfig(c)) {
+			t.Errorf("%s is not same as logger.xml:\n%+v\n%+v", file, c, expected)
+		}
+	}
+}
+
+func TestConfigBuilder(t *testing.T) {
+	dir, restore := isolateState(t)
+	defer restore()
+
+	c, err := NewConfigBuilder().
+		Property("LOG_PATH", dir).
+		FileLogger("app", "${LOG_PATH}/app.log", "${LOG_PATH}/app-%{i}.log").
+		Level("INFO", "").
+		Format("text", "%{Level} %{Message}").
+		Rolling("@daily", 100, 16).
+		DefaultFilter("app").
+		PackageFilter("main", "app").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := InitWithConfig(c); err != nil {
+		t.Fatal(err)
+	}
+
+	Debug("debug message")
+	Info("info message")
+
+	content, err := ioutil.ReadFile(path.Join(dir, "app.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.TrimSpace(string(content)) != "INFO info message" {
+		t.Errorf("unexpected content: %s", content)
+	}
+
+	_, err = NewConfigBuilder().
+		Level("INFO", "").
+		ConsoleLogger("console").
+		ConsoleLogger("console").
+		PackageFilter("main", "missing").
+		Build()
+	if err == nil || len(err.(MultiError)) != 3 {
+		t.Errorf("unexpected build error: %v", err)
+	}
+
+	// a format without type is a text format
+	_, err = NewConfigBuilder().
+		ConsoleLogger("console").
+		Format("", "%{Level} %{Mesage}").
+		DefaultFilter("console").
+		Build()
+	if err == nil || !strings.Contains(err.Error(), "unknown format placeholder %{Mesage}") {
+		t.Errorf("unexpected build error of untyped format: %v", err)
+	}
+}
+
+// The config built first is not changed by using the builder again
+func TestConfigBuilderBuildTwice(t *testing.T) {
+	newBuilder := func() *ConfigBuilder {
+		return NewConfigBuilder().
+			HTTPLogger("http", "http://127.0.0.1:8080/logs").
+			Level("INFO", "").
+			Header("X-Token", "a").
+			DefaultFilter("http").
+			PackageFilter("main", "http")
+	}
+
+	builder := newBuilder()
+	first, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	builder.
+		Level("ERROR", "").
+		Header("X-Tenant", "b").
+		ConsoleLogger("console").
+		DefaultFilter("console").
+		PackageFilter("app", "console")
+	if _, err := builder.Build(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := newBuilder().Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(first, expected) {
+		t.Errorf("the first config is changed: %+v", first)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	const content = `<Configuration strict="true">
+    <Loggers>
+        <Logger name="app" target="FILE" fileName="${LOG_PATH}/app.log" filePattern="app-%{index}.log">
+            <Format type="text">%{Level} %{Mesage}</Format>
+            <Level>
+                <Allow>INF</Allow>
+            </Level>
+            <Rolling>
+                <TimeBased>@weekly daily</TimeBased>
+            </Rolling>
+        </Logger>
+        <Logger name="app" target="SOCKET"/>
+    </Loggers>
+    <Filters>
+        <DefaultFilter>
+            <Filter>
+                <Logger>missing</Logger>
+            </Filter>
+        </DefaultFilter>
+    </Filters>
+</Configuration>`
+
+	dir, err := ioutil.TempDir("", "logger-validate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	configFile := path.Join(dir, "logger.xml")
+	if err := ioutil.WriteFile(configFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewConfig(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.Validate()
+	if err == nil {
+		t.Fatal("invalid config passed validation")
+	}
+
+	expected := []string{
+		`line 3, column 9: Loggers[0]: undefined property ${LOG_PATH}`,
+		`line 3, column 9: Loggers[0]: unknown file pattern function %{index}`,
+		`line 4, column 13: Loggers[0].Format: unknown format placeholder %{Mesage}`,
+		`line 6, column 17: Loggers[0].Level.Allow: unknown level "INF"`,
+		`line 9, column 17: Loggers[0].Rolling.TimeBased: invalid cron spec "@weekly daily"`,
+		`line 12, column 9: Loggers[1]: duplicate logger name app`,
+		`line 12, column 9: Loggers[1]: unknown target "SOCKET"`,
+		`line 17, column 17: DefaultFilter.Loggers[0]: undefined logger missing`,
+	}
+
+	errs := err.(MultiError)
+	if len(errs) != len(expected) {
+		t.Fatalf("unexpected errors: %v", err)
+	}
+
+	for i, e := range errs {
+		if !strings.HasPrefix(e.Error(), expected[i]) {
+			t.Errorf("unexpected error %q, expected %q", e.Error(), expected[i])
+		}
+	}
+
+	if Init(configFile) == nil {
+		t.Error("invalid config is initialized in strict mode")
+	}
+}
+
+func TestResolveProperties(t *testing.T) {
+	os.Setenv("LOGGER_TEST_HOME", "/home/logger")
+	defer os.Unsetenv("LOGGER_TEST_HOME")
+
+	properties := []Property{
+		{Name: "LOG_FILE", Value: "${LOG_PATH}/${APP:-app}.log"},
+		{Name: "LOG_PATH", Value: "${BASE}/logs"},
+		{Name: "BASE", Value: "${env:LOGGER_TEST_HOME}"},
+		{Name: "EMPTY", Value: "${env:LOGGER_TEST_UNDEFINED:-default}"},
+		{Name: "A", Value: "${B}"},
+		{Name: "B", Value: "${A}"},
+	}
+
+	resolved, err := ResolveProperties(properties)
+	if err == nil || !strings.Contains(err.Error(), "property cycle A -> B -> A") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if resolved["LOG_FILE"] != "/home/logger/logs/app.log" || resolved["EMPTY"] != "default" {
+		t.Errorf("unexpected properties: %v", resolved)
+	}
+
+	c := &Config{Properties: properties[:3]}
+	c.SetProperties(map[string]string{"APP": "server", "BASE": "/var"})
+
+	resolved, err = ResolveProperties(c.Properties)
+	if err != nil || resolved["LOG_FILE"] != "/var/logs/server.log" {
+		t.Errorf("unexpected overridden properties: %v, %v", resolved, err)
+	}
+}
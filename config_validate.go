@@ -0,0 +1,512 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package logger
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/xml"
+	"fmt"
+	"github.com/robfig/cron"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Position of an element in the config file
+type Position struct {
+	Line   int
+	Column int
+}
+
+// ConfigError describes a problem of the config
+type ConfigError struct {
+	Path     string // such as Loggers[1].Level.Allow
+	Position Position
+	Message  string
+}
+
+func (this *ConfigError) Error() string {
+	if this.Position.Line > 0 {
+		return fmt.Sprintf("line %d, column %d: %s: %s", this.Position.Line, this.Position.Column, this.Path, this.Message)
+	}
+
+	return fmt.Sprintf("%s: %s", this.Path, this.Message)
+}
+
+var (
+	loggerTargets    = []string{"STDOUT", "FILE", "SYSLOG", "NETWORK", "HTTP", "DATABASE", "MULTI", "MEMORY"}
+	formatTypes      = []string{"", "text", "json"}
+	compressTypes    = []string{"", "gzip"}
+	overflowPolicies = []string{"", "block", "drop", "drop-lowest-level"}
+	levelNames       = []string{"ALL", "TRACE", "DEBUG", "INFO", "WARN", "ERROR", "FATAL", "OFF"}
+	// functions supported by fileName and filePattern
+	filePatternFunctions = []string{"date", "i"}
+
+	filePatternRegexp = regexp.MustCompile(`%\{([a-zA-Z_][0-9a-zA-Z_/:-]*)\}`)
+	textFormatRegexp  = regexp.MustCompile(`%\{([a-zA-Z_][0-9a-zA-Z\s\._/:+-]*)\}`)
+)
+
+// Validate the config, returns a MultiError of *ConfigError listing every problem
+func (this *Config) Validate() error {
+	var (
+		errs       MultiError
+		names      = map[string]bool{}
+		properties = map[string]bool{}
+	)
+
+	addError := func(path, format string, args ...interface{}) {
+		errs = append(errs, &ConfigError{
+			Path:     path,
+			Position: this.position(path),
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	checkProperties := func(path, str string) {
+		for _, match := range propertyRegexp.FindAllStringSubmatch(str, -1) {
+			reference := parsePropertyReference(match)
+			if reference.hasDefault {
+				continue
+			}
+
+			if reference.env {
+				if _, ok := os.LookupEnv(reference.name); !ok {
+					addError(path, "undefined environment variable %s", match[0])
+				}
+			} else if !properties[reference.name] {
+				addError(path, "undefined property %s", match[0])
+			}
+		}
+	}
+
+	for i, v := range this.Properties {
+		if v.Name == "" {
+			addError(fmt.Sprintf("Properties[%d]", i), "property name is empty")
+			continue
+		}
+
+		properties[v.Name] = true
+	}
+
+	for i, v := range this.Properties {
+		checkProperties(fmt.Sprintf("Properties[%d]", i), v.Value)
+	}
+
+	if _, err := ResolveProperties(this.Properties); err != nil {
+		for _, e := range err.(MultiError) {
+			addError("Properties", "%s", e.Error())
+		}
+	}
+
+	for i, v := range this.Loggers {
+		path := fmt.Sprintf("Loggers[%d]", i)
+
+		if v.Name == "" {
+			addError(path, "logger name is empty")
+		} else if names[v.Name] {
+			addError(path, "duplicate logger name %s", v.Name)
+		}
+		names[v.Name] = true
+
+		if !containsString(loggerTargets, v.Target, true) {
+			addError(path, "unknown target %q", v.Target)
+		}
+
+		if v.Level.Allow != "" && !containsString(levelNames, v.Level.Allow, false) {
+			addError(path+".Level.Allow", "unknown level %q", v.Level.Allow)
+		}
+
+		if v.Level.Deny != "" && !containsString(levelNames, v.Level.Deny, false) {
+			addError(path+".Level.Deny", "unknown level %q", v.Level.Deny)
+		}
+
+		if v.FlushLevel != "" && !containsString(levelNames, v.FlushLevel, false) {
+			addError(path, "unknown flush level %q", v.FlushLevel)
+		}
+
+		if !containsString(formatTypes, v.Format.Type, false) {
+			addError(path+".Format", "unknown format type %q", v.Format.Type)
+		}
+
+		checkProperties(path+".Format", v.Format.Value)
+		if formatType := strings.ToLower(v.Format.Type); formatType == "" || formatType == "text" {
+			for _, match := range textFormatRegexp.FindAllStringSubmatch(v.Format.Value, -1) {
+				name := strings.SplitN(match[1], ":", 2)[0]
+				if !containsString(textFormatPlaceholders, name, false) {
+					addError(path+".Format", "unknown format placeholder %s", match[0])
+				}
+			}
+		}
+
+		if !containsString(jsonLevelCases, v.Format.LevelCase, false) {
+			addError(path+".Format", "unknown level case %q", v.Format.LevelCase)
+		}
+
+		if !containsString(jsonCallers, v.Format.Caller, false) {
+			addError(path+".Format", "unknown caller %q", v.Format.Caller)
+		}
+
+		if _, err := parseJSONKeys(v.Format.Keys); err != nil {
+			addError(path+".Format", "keys: %s", err.Error())
+		}
+
+		checkProperties(path+".Format", v.Format.Extra)
+		if _, err := parsePairs(v.Format.Extra); err != nil {
+			addError(path+".Format", "extra: %s", err.Error())
+		}
+
+		if !containsString(colorModes, v.Format.Color, false) {
+			addError(path+".Format", "unknown color mode %q", v.Format.Color)
+		}
+
+		for j, color := range v.Colors {
+			colorPath := fmt.Sprintf("%s.Colors[%d]", path, j)
+			if !containsString(levelNames, color.Level, false) {
+				addError(colorPath, "unknown level %q", color.Level)
+			}
+
+			if _, err := colorSequence(color.Color, color.Bold); err != nil {
+				addError(colorPath, "%s", err.Error())
+			}
+		}
+
+		if !containsString(compressTypes, v.Compress, false) {
+			addError(path, "unknown compress %q", v.Compress)
+		}
+
+		if !containsString(overflowPolicies, v.Overflow, false) {
+			addError(path, "unknown overflow %q", v.Overflow)
+		}
+
+		if !containsString(samplingTypes, v.Sampling.Type, false) {
+			addError(path+".Sampling", "unknown sampling type %q", v.Sampling.Type)
+		} else if strings.EqualFold(v.Sampling.Type, SamplingToken) && v.Sampling.Rate <= 0 {
+			addError(path+".Sampling", "sampling rate must be positive")
+		} else if v.Sampling.First < 0 || v.Sampling.Thereafter < 0 {
+			addError(path+".Sampling", "sampling first and thereafter must not be negative")
+		}
+
+		if v.Dedup.Window < 0 {
+			addError(path+".Dedup", "dedup window must not be negative")
+		}
+
+		if v.Target == "FILE" {
+			if v.FileName == "" {
+				addError(path, "fileName is empty")
+			}
+
+			for _, str := range []string{v.FileName, v.FilePattern} {
+				checkProperties(path, str)
+				for _, match := range filePatternRegexp.FindAllStringSubmatch(str, -1) {
+					name := strings.SplitN(match[1], ":", 2)[0]
+					if !containsString(filePatternFunctions, name, false) {
+						addError(path, "unknown file pattern function %s", match[0])
+					}
+				}
+			}
+		}
+
+		if v.Target == "SYSLOG" {
+			if !containsString(syslogNetworks, v.Network, false) {
+				addError(path, "unknown network %q", v.Network)
+			}
+
+			if v.Network != "" && v.Address == "" {
+				addError(path, "address is empty")
+			}
+			checkProperties(path, v.Address)
+
+			if v.Facility != "" && !containsString(syslogFacilities, v.Facility, false) {
+				addError(path, "unknown syslog facility %q", v.Facility)
+			}
+
+			if !containsString(syslogFormats, v.SyslogFormat, false) {
+				addError(path, "unknown syslog format %q", v.SyslogFormat)
+			}
+		}
+
+		if v.Target == "NETWORK" {
+			if !containsString(networkNetworks, v.Network, true) {
+				addError(path, "unknown network %q", v.Network)
+			}
+
+			if v.Address == "" {
+				addError(path, "address is empty")
+			}
+
+			if !containsString(networkFramings, v.Framing, false) {
+				addError(path, "unknown framing %q", v.Framing)
+			}
+
+			checkProperties(path, v.Address)
+			checkProperties(path, v.SpoolFile)
+		}
+
+		if v.Target == "HTTP" {
+			checkProperties(path, v.URL)
+			if u, err := url.Parse(v.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				addError(path, "invalid url %q", v.URL)
+			}
+
+			if !containsString(batchFormats, v.BatchFormat, false) {
+				addError(path, "unknown batch format %q", v.BatchFormat)
+			}
+
+			for j, header := range v.Headers {
+				headerPath := fmt.Sprintf("%s.Headers[%d]", path, j)
+				if header.Name == "" {
+					addError(headerPath, "header name is empty")
+				}
+				checkProperties(headerPath, header.Value)
+			}
+		}
+
+		if v.Target == "DATABASE" {
+			if v.Driver == "" {
+				addError(path, "driver is empty")
+			} else if !containsString(sql.Drivers(), v.Driver, true) {
+				addError(path, "database driver %q is not imported", v.Driver)
+			}
+			checkProperties(path, v.DataSource)
+
+			if v.Table != "" && !tableRegexp.MatchString(v.Table) {
+				addError(path, "invalid table name %q", v.Table)
+			}
+		}
+
+		if v.Target == "FILE" || v.Target == "DATABASE" {
+			if v.Rolling.TimeBased != "" {
+				_, err := cron.Parse(v.Rolling.TimeBased)
+				if err != nil {
+					addError(path+".Rolling.TimeBased", "invalid cron spec %q: %s", v.Rolling.TimeBased, err.Error())
+				}
+			}
+		}
+	}
+
+	for i, v := range this.Loggers {
+		path := fmt.Sprintf("Loggers[%d]", i)
+
+		if v.Target == "MULTI" {
+			if len(v.Appenders) == 0 {
+				addError(path, "no appender defined")
+			}
+
+			for j, appender := range v.Appenders {
+				appenderPath := fmt.Sprintf("%s.Appenders[%d]", path, j)
+				if !names[appender.Name] {
+					addError(appenderPath, "undefined logger %s", appender.Name)
+				}
+
+				if appender.Level.Allow != "" && !containsString(levelNames, appender.Level.Allow, false) {
+					addError(appenderPath+".Level.Allow", "unknown level %q", appender.Level.Allow)
+				}
+
+				if appender.Level.Deny != "" && !containsString(levelNames, appender.Level.Deny, false) {
+					addError(appenderPath+".Level.Deny", "unknown level %q", appender.Level.Deny)
+				}
+
+				// the errors of an async logger are not returned to fail over
+				if referenced := findLogger(this, appender.Name); v.Failover && referenced != nil && referenced.Async {
+					addError(appenderPath, "async logger %s can not be an appender with failover", appender.Name)
+				}
+			}
+		}
+
+		if v.Target == "MEMORY" {
+			if v.DumpTo != "" && !names[v.DumpTo] {
+				addError(path, "undefined dump logger %s", v.DumpTo)
+			}
+
+			if v.DumpLevel != "" && !containsString(levelNames, v.DumpLevel, false) {
+				addError(path, "unknown dump level %q", v.DumpLevel)
+			}
+		}
+
+		if chain := this.referenceCycle(v.Name, nil); chain != nil {
+			addError(path, "logger reference cycle %s", strings.Join(chain, " -> "))
+		}
+	}
+
+	checkFilter := func(path string, filter Filter) {
+		for j, name := range filter.Loggers {
+			if !names[name] {
+				addError(fmt.Sprintf("%s.Loggers[%d]", path, j), "undefined logger %s", name)
+			}
+		}
+	}
+
+	checkFilter("DefaultFilter", this.DefaultFilter)
+	for i, filter := range this.PackageFilters {
+		path := fmt.Sprintf("PackageFilters[%d]", i)
+		if filter.Name == "" {
+			addError(path, "package name is empty")
+		} else if _, err := matchPackage(filter.Name, ""); err != nil {
+			addError(path, "invalid package pattern %q", filter.Name)
+		}
+
+		if filter.Level != "" && !containsString(levelNames, filter.Level, false) {
+			addError(path, "unknown level %q", filter.Level)
+		}
+
+		checkFilter(path, filter)
+	}
+
+	// report from top to bottom of the config file, the errors without position are the last
+	sort.SliceStable(errs, func(i, j int) bool {
+		a, b := errs[i].(*ConfigError).Position, errs[j].(*ConfigError).Position
+		if a.Line == 0 || b.Line == 0 {
+			return a.Line > b.Line
+		}
+
+		return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+	})
+
+	return errs.ErrorOrNil()
+}
+
+// Returns the chain of loggers referenced from name back to a logger in chain
+func (this *Config) referenceCycle(name string, chain []string) []string {
+	for i, v := range chain {
+		if v == name {
+			return append(chain[i:], name)
+		}
+	}
+
+	v := findLogger(this, name)
+	if v == nil {
+		return nil
+	}
+
+	for _, reference := range v.references() {
+		if cycle := this.referenceCycle(reference, append(chain, name)); cycle != nil {
+			return cycle
+		}
+	}
+
+	return nil
+}
+
+// Returns the position of path or its nearest parent
+func (this *Config) position(path string) Position {
+	for path != "" {
+		if position, ok := this.positions[path]; ok {
+			return position
+		}
+
+		i := strings.LastIndexAny(path, ".[")
+		if i < 0 {
+			break
+		}
+		path = path[:i]
+	}
+
+	return Position{}
+}
+
+// Scan the positions of xml elements, the keys are the paths used by ConfigError
+func xmlPositions(data []byte) map[string]Position {
+	type element struct {
+		name string
+		path string
+	}
+
+	var (
+		positions = map[string]Position{}
+		counters  = map[string]int{}
+		stack     []element
+		decoder   = xml.NewDecoder(bytes.NewReader(data))
+	)
+
+	for {
+		offset := decoder.InputOffset()
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			var parent element
+			if len(stack) > 0 {
+				parent = stack[len(stack)-1]
+			}
+
+			name := t.Name.Local
+			path := parent.path
+			index := func(format string) string {
+				key := parent.path + "|" + name
+				i := counters[key]
+				counters[key] = i + 1
+
+				return fmt.Sprintf(format, i)
+			}
+
+			switch {
+			case parent.name == "Properties" && name == "Property":
+				path = index("Properties[%d]")
+			case parent.name == "Loggers" && name == "Logger":
+				path = index("Loggers[%d]")
+			case parent.name == "Headers" && name == "Header", parent.name == "Appenders" && name == "Appender":
+				path = index(parent.path + "[%d]")
+			case parent.name == "Logger" || parent.name == "Appender" || parent.name == "Level" || parent.name == "Rolling":
+				path = parent.path + "." + name
+			case parent.name == "DefaultFilter" && name == "Filter":
+				path = "DefaultFilter"
+			case parent.name == "PackageFilter" && name == "Filter":
+				path = index("PackageFilters[%d]")
+			case parent.name == "Filter" && name == "Logger":
+				path = index(parent.path + ".Loggers[%d]")
+			}
+
+			if path != "" {
+				if _, ok := positions[path]; !ok {
+					positions[path] = offsetPosition(data, offset)
+				}
+			}
+
+			stack = append(stack, element{name: name, path: path})
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+
+	return positions
+}
+
+func offsetPosition(data []byte, offset int64) Position {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := int(offset) - bytes.LastIndexByte(before, '\n')
+
+	return Position{Line: line, Column: column}
+}
+
+func containsString(list []string, str string, caseSensitive bool) bool {
+	for _, v := range list {
+		if v == str || (!caseSensitive && strings.EqualFold(v, str)) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,113 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package logger
+
+import (
+	"context"
+	"sync"
+)
+
+// Extract a value from context, returns false if the value is not present
+type ContextExtractor func(ctx context.Context) (interface{}, bool)
+
+type contextKey string
+
+const (
+	ContextRequestID = "request_id"
+	ContextUserID    = "user_id"
+	ContextTraceID   = "trace_id"
+	ContextSpanID    = "span_id"
+)
+
+var (
+	contextExtractorMutex sync.RWMutex
+	contextExtractorMap   = map[string]ContextExtractor{
+		ContextRequestID: ContextValueExtractor(ContextRequestID),
+		ContextUserID:    ContextValueExtractor(ContextUserID),
+		ContextTraceID:   ContextValueExtractor(ContextTraceID),
+		ContextSpanID:    ContextValueExtractor(ContextSpanID),
+	}
+)
+
+// Register an extractor, the extracted value is available as %{Ctx:name} in TextFormatter
+func RegisterContextExtractor(name string, extractor ContextExtractor) {
+	contextExtractorMutex.Lock()
+	defer contextExtractorMutex.Unlock()
+
+	if extractor == nil {
+		delete(contextExtractorMap, name)
+		return
+	}
+
+	contextExtractorMap[name] = extractor
+}
+
+func UnregisterContextExtractor(name string) {
+	RegisterContextExtractor(name, nil)
+}
+
+// Returns an extractor for the value stored by ContextWithValue
+func ContextValueExtractor(name string) ContextExtractor {
+	return func(ctx context.Context) (interface{}, bool) {
+		value := ctx.Value(contextKey(name))
+
+		return value, value != nil
+	}
+}
+
+// Returns a copy of ctx with the named value,
+// which can be extracted by ContextValueExtractor(name)
+func ContextWithValue(ctx context.Context, name string, value interface{}) context.Context {
+	return context.WithValue(ctx, contextKey(name), value)
+}
+
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return ContextWithValue(ctx, ContextRequestID, requestID)
+}
+
+func ContextWithUserID(ctx context.Context, userID string) context.Context {
+	return ContextWithValue(ctx, ContextUserID, userID)
+}
+
+func ContextWithTraceID(ctx context.Context, traceID string) context.Context {
+	return ContextWithValue(ctx, ContextTraceID, traceID)
+}
+
+func ContextWithSpanID(ctx context.Context, spanID string) context.Context {
+	return ContextWithValue(ctx, ContextSpanID, spanID)
+}
+
+// Run all registered extractors with ctx
+func ExtractContext(ctx context.Context) map[string]interface{} {
+	if ctx == nil {
+		return nil
+	}
+
+	contextExtractorMutex.RLock()
+	defer contextExtractorMutex.RUnlock()
+
+	var values map[string]interface{}
+	for name, extractor := range contextExtractorMap {
+		value, ok := extractor(ctx)
+		if !ok {
+			continue
+		}
+
+		if values == nil {
+			values = map[string]interface{}{}
+		}
+		values[name] = value
+	}
+
+	return values
+}
@@ -0,0 +1,233 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package logger
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const DefaultTable = "logs"
+
+var (
+	tableRegexp = regexp.MustCompile(`^[a-zA-Z_][0-9a-zA-Z_]*(\.[a-zA-Z_][0-9a-zA-Z_]*)?$`)
+
+	databaseColumns = []string{"log_time", "level", "prefix", "package_name", "file_name", "line", "message", "fields"}
+)
+
+// DatabaseLogger inserts log entries into table by database/sql in batches,
+// the table is created if not exists, the database driver must be imported by the application
+type DatabaseLogger struct {
+	*LoggerWriter
+
+	db        *sql.DB
+	driver    string
+	table     string
+	retention int // days
+	config    Logger
+	batch     *BatchWriter
+}
+
+func NewDatabaseLogger(level LogLevel, driver, dataSource, table string) (*DatabaseLogger, error) {
+	if table == "" {
+		table = DefaultTable
+	}
+
+	if !tableRegexp.MatchString(table) {
+		return nil, fmt.Errorf("invalid table name %s", table)
+	}
+
+	db, err := sql.Open(driver, dataSource)
+	if err != nil {
+		return nil, err
+	}
+
+	databaseLogger := &DatabaseLogger{
+		db:     db,
+		driver: strings.ToLower(driver),
+		table:  table,
+	}
+
+	err = databaseLogger.createTable()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	databaseLogger.batch = NewBatchWriter(DefaultBatchSize, DefaultFlushInterval*time.Millisecond, databaseLogger.insert)
+	databaseLogger.LoggerWriter = NewLoggerWriter(ioutil.Discard, level)
+	databaseLogger.SetEntryWriter(databaseLogger)
+	databaseLogger.exitFlush = databaseLogger.Flush
+
+	return databaseLogger, nil
+}
+
+func NewDatabaseLoggerWithConfig(v Logger) (*DatabaseLogger, error) {
+	databaseLogger, err := NewDatabaseLogger(ConvertString2Level(v.Level.Allow), v.Driver,
+		VariableReplaceByConfig(v.DataSource), v.Table)
+	if err != nil {
+		return nil, err
+	}
+
+	databaseLogger.SetDenyLevel(ConvertString2Level(v.Level.Deny))
+	databaseLogger.config = v
+	databaseLogger.retention = v.Retention
+
+	if v.BatchSize > 0 || v.FlushInterval > 0 {
+		databaseLogger.SetBatch(v.BatchSize, time.Duration(v.FlushInterval)*time.Millisecond)
+	}
+
+	if v.FlushLevel != "" {
+		databaseLogger.batch.SetFlushLevel(ConvertString2Level(v.FlushLevel))
+	}
+
+	return databaseLogger, nil
+}
+
+// Set the max entries of a batch and the interval of inserting the batch not full,
+// the default value is used if it is not positive
+func (this *DatabaseLogger) SetBatch(batchSize int, flushInterval time.Duration) {
+	this.batch.SetBatch(batchSize, flushInterval)
+}
+
+// Set the days to keep the entries, 0 means keep forever
+func (this *DatabaseLogger) SetRetention(days int) {
+	this.retention = days
+}
+
+// Implement interface EntryWriter
+func (this *DatabaseLogger) WriteEntry(entry *Entry) error {
+	return this.batch.Write(entry)
+}
+
+// Insert the pending entries
+func (this *DatabaseLogger) Flush() error {
+	err := this.LoggerWriter.Flush()
+	if err != nil {
+		return err
+	}
+
+	return this.batch.Flush()
+}
+
+// Insert the pending entries and close the database
+func (this *DatabaseLogger) Close() error {
+	var errs MultiError
+
+	err := this.LoggerWriter.Close()
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	err = this.batch.Close()
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	err = this.db.Close()
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	return errs.ErrorOrNil()
+}
+
+// Delete the entries older than the retention days, used by the cron job
+func (this *DatabaseLogger) Purge() {
+	if this.retention <= 0 {
+		return
+	}
+
+	before := time.Now().UTC().AddDate(0, 0, -this.retention)
+
+	_, err := this.db.Exec(fmt.Sprintf("DELETE FROM %s WHERE log_time < %s", this.table, this.placeholder(1)), before)
+	if err != nil {
+		DefaultConsoleLogger().Errorf("purge table %s error: %s", this.table, err.Error())
+	}
+}
+
+func (this *DatabaseLogger) createTable() error {
+	timeType := "TIMESTAMP"
+	if this.driver == "mysql" {
+		timeType = "DATETIME(6)"
+	}
+
+	_, err := this.db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	log_time %s NOT NULL,
+	level VARCHAR(8) NOT NULL,
+	prefix VARCHAR(255),
+	package_name VARCHAR(255),
+	file_name VARCHAR(255),
+	line INTEGER,
+	message TEXT,
+	fields TEXT
+)`, this.table, timeType))
+
+	return err
+}
+
+// Insert the batch in a transaction
+func (this *DatabaseLogger) insert(batch []*Entry) error {
+	placeholders := make([]string, len(databaseColumns))
+	for i := range placeholders {
+		placeholders[i] = this.placeholder(i + 1)
+	}
+
+	tx, err := this.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		this.table, strings.Join(databaseColumns, ", "), strings.Join(placeholders, ", ")))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for _, entry := range batch {
+		var fields interface{}
+		if values := MergeFields(entry.Fields, entry.Ctx); len(values) > 0 {
+			data, err := json.Marshal(values)
+			if err != nil {
+				data, _ = json.Marshal(fmt.Sprintf("%+v", values))
+			}
+			fields = string(data)
+		}
+
+		_, err = stmt.Exec(entry.Time.UTC(), ConvertLevel2String(entry.Level), entry.Prefix, entry.PackageName,
+			entry.File, entry.Line, strings.TrimRight(entry.Message, "\n"), fields)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// The placeholder of the nth parameter
+func (this *DatabaseLogger) placeholder(n int) string {
+	switch this.driver {
+	case "postgres", "pgx":
+		return fmt.Sprintf("$%d", n)
+	default:
+		return "?"
+	}
+}
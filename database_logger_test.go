@@ -0,0 +1,243 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package logger
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A database/sql driver recording the executed statements, also appended to the file named
+// by the data source if not empty. The test against sqlite is in test/sqlite
+type recordDriver struct {
+	mutex      sync.Mutex
+	statements []string
+}
+
+type recordConn struct {
+	driver *recordDriver
+	file   string
+}
+
+type recordStmt struct {
+	driver *recordDriver
+	file   string
+	query  string
+}
+
+type recordTx struct{}
+
+var testDriver = &recordDriver{}
+
+func init() {
+	sql.Register("logger-record", testDriver)
+}
+
+func (this *recordDriver) Open(name string) (driver.Conn, error) {
+	return &recordConn{driver: this, file: name}, nil
+}
+
+func (this *recordDriver) take() []string {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	statements := this.statements
+	this.statements = nil
+
+	return statements
+}
+
+func (this *recordConn) Prepare(query string) (driver.Stmt, error) {
+	return &recordStmt{driver: this.driver, file: this.file, query: strings.Join(strings.Fields(query), " ")}, nil
+}
+
+func (this *recordConn) Close() error {
+	return nil
+}
+
+func (this *recordConn) Begin() (driver.Tx, error) {
+	return recordTx{}, nil
+}
+
+func (this *recordStmt) Close() error {
+	return nil
+}
+
+func (this *recordStmt) NumInput() int {
+	return -1
+}
+
+func (this *recordStmt) Exec(args []driver.Value) (driver.Result, error) {
+	this.driver.mutex.Lock()
+	defer this.driver.mutex.Unlock()
+
+	statement := this.query
+	for _, arg := range args {
+		if t, ok := arg.(time.Time); ok {
+			arg = t.Format("2006-01-02")
+		}
+		statement += fmt.Sprintf(" [%v]", arg)
+	}
+	this.driver.statements = append(this.driver.statements, statement)
+
+	if this.file != "" {
+		f, err := os.OpenFile(this.file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		if _, err := f.WriteString(statement + "\n"); err != nil {
+			return nil, err
+		}
+	}
+
+	return driver.RowsAffected(1), nil
+}
+
+func (this *recordStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("query is not supported")
+}
+
+func (recordTx) Commit() error {
+	return nil
+}
+
+func (recordTx) Rollback() error {
+	return nil
+}
+
+func TestDatabaseLogger(t *testing.T) {
+	testDriver.take()
+
+	databaseLogger, err := NewDatabaseLoggerWithConfig(Logger{
+		Driver:    "logger-record",
+		Table:     "app_logs",
+		BatchSize: 2,
+		Retention: 7,
+		Level:     Level{Allow: "ALL"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	databaseLogger.closeFilter = true
+	databaseLogger.SetSkipCallerDepth(4)
+	databaseLogger.SetFormatter(NewTextFormatterWithFormat("%{Message}"))
+
+	databaseLogger.Info("first")
+	databaseLogger.WithFields(map[string]interface{}{"user": "ron"}).Warn("second")
+
+	err = databaseLogger.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	databaseLogger.Purge()
+	databaseLogger.Close()
+
+	var (
+		today    = time.Now().UTC().Format("2006-01-02")
+		expired  = time.Now().UTC().AddDate(0, 0, -7).Format("2006-01-02")
+		insert   = "INSERT INTO app_logs (log_time, level, prefix, package_name, file_name, line, message, fields) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+		expected = []string{
+			"CREATE TABLE IF NOT EXISTS app_logs ( log_time TIMESTAMP NOT NULL, level VARCHAR(8) NOT NULL, prefix VARCHAR(255), package_name VARCHAR(255), file_name VARCHAR(255), line INTEGER, message TEXT, fields TEXT )",
+			insert + " [" + today + "] [INFO] [" + databaseLogger.prefix + "] [github.com/ronzxy/go-logger] [database_logger_test.go] [LINE] [first] [<nil>]",
+			insert + " [" + today + "] [WARN] [" + databaseLogger.prefix + "] [github.com/ronzxy/go-logger] [database_logger_test.go] [LINE] [second] [{\"user\":\"ron\"}]",
+			"DELETE FROM app_logs WHERE log_time < ? [" + expired + "]",
+		}
+		statements = testDriver.take()
+	)
+
+	if len(statements) != len(expected) {
+		t.Fatalf("unexpected statements %q", statements)
+	}
+
+	// the line of the caller
+	line := regexp.MustCompile(`\[database_logger_test\.go\] \[\d+\]`)
+	for i, statement := range statements {
+		statement = line.ReplaceAllString(statement, "[database_logger_test.go] [LINE]")
+		if statement != expected[i] {
+			t.Errorf("unexpected statement %q, expected %q", statement, expected[i])
+		}
+	}
+}
+
+// The pending batch is inserted before Fatal exits
+func TestDatabaseLoggerFatalExit(t *testing.T) {
+	if dataSource := os.Getenv("LOGGER_TEST_DATA_SOURCE"); dataSource != "" {
+		databaseLogger, err := NewDatabaseLoggerWithConfig(Logger{
+			Driver:        "logger-record",
+			DataSource:    dataSource,
+			Table:         "app_logs",
+			BatchSize:     100,
+			FlushInterval: 60000,
+			Level:         Level{Allow: "ALL"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		databaseLogger.closeFilter = true
+		databaseLogger.SetFormatter(NewTextFormatterWithFormat("%{Message}"))
+
+		databaseLogger.Write(INFO, "info")
+		databaseLogger.FatalWithExit(true, "fatal")
+
+		return
+	}
+
+	dir, err := ioutil.TempDir("", "database")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	dataSource := path.Join(dir, "statements")
+
+	cmd := exec.Command(os.Args[0], "-test.run", "^TestDatabaseLoggerFatalExit$")
+	cmd.Env = append(os.Environ(), "LOGGER_TEST_DATA_SOURCE="+dataSource)
+
+	if err := cmd.Run(); err == nil {
+		t.Error("unexpected exit without error")
+	} else if e, ok := err.(*exec.ExitError); !ok || e.ExitCode() != 1 {
+		t.Errorf("unexpected exit %v", err)
+	}
+
+	content, err := ioutil.ReadFile(dataSource)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var inserted []string
+	for _, statement := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		if strings.HasPrefix(statement, "INSERT") {
+			inserted = append(inserted, statement)
+		}
+	}
+
+	if len(inserted) != 2 || !strings.Contains(inserted[0], "[INFO]") || !strings.Contains(inserted[1], "[FATAL] ") {
+		t.Errorf("unexpected inserted %q", inserted)
+	}
+}
@@ -0,0 +1,140 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package logger
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const DefaultDedupWindow = 1000 // ms
+
+// Deduplicator collapses the consecutive identical entries of the same level and call site.
+// A run of identical entries ends when a different entry is written or the window since
+// the first entry expires, then a "last message repeated N times" entry is written
+type Deduplicator struct {
+	window time.Duration
+
+	mutex    sync.Mutex
+	first    *Entry // the first entry of the run
+	message  string
+	repeated int
+	last     time.Time
+	run      uint64 // increased on every run, guard the expired timers
+	timer    *time.Timer
+	output   func(entry *Entry) error // write the repeated entry of the expired run
+}
+
+// Collapse the identical entries within window, the default window is used if it is not positive
+func NewDeduplicator(window time.Duration) *Deduplicator {
+	if window <= 0 {
+		window = DefaultDedupWindow * time.Millisecond
+	}
+
+	return &Deduplicator{window: window}
+}
+
+func NewDeduplicatorWithConfig(v Dedup) *Deduplicator {
+	return NewDeduplicator(time.Duration(v.Window) * time.Millisecond)
+}
+
+// Returns whether the entry is written, and the repeated entry of the ended run if any
+func (this *Deduplicator) Check(entry *Entry) (bool, *Entry) {
+	message := fmt.Sprint(entry.Args...)
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if this.first != nil && this.same(entry, message) && entry.Time.Sub(this.first.Time) < this.window {
+		this.repeated++
+		this.last = entry.Time
+
+		// end the run by timer in case no more entries are written
+		if this.repeated == 1 && this.output != nil {
+			run := this.run
+			this.timer = time.AfterFunc(this.window-entry.Time.Sub(this.first.Time), func() {
+				this.expire(run)
+			})
+		}
+
+		return false, nil
+	}
+
+	repeated := this.end()
+
+	this.first = entry
+	this.message = message
+
+	return true, repeated
+}
+
+func (this *Deduplicator) setOutput(output func(entry *Entry) error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.output = output
+}
+
+// End the current run, returns the repeated entry if any
+func (this *Deduplicator) End() *Entry {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	return this.end()
+}
+
+func (this *Deduplicator) expire(run uint64) {
+	this.mutex.Lock()
+	if run != this.run {
+		this.mutex.Unlock()
+		return
+	}
+
+	repeated := this.end()
+	output := this.output
+	this.mutex.Unlock()
+
+	if repeated != nil {
+		output(repeated)
+	}
+}
+
+func (this *Deduplicator) same(entry *Entry, message string) bool {
+	return entry.Level == this.first.Level &&
+		entry.File == this.first.File &&
+		entry.Line == this.first.Line &&
+		message == this.message
+}
+
+func (this *Deduplicator) end() *Entry {
+	if this.timer != nil {
+		this.timer.Stop()
+		this.timer = nil
+	}
+
+	var repeated *Entry
+	if this.first != nil && this.repeated > 0 {
+		entry := *this.first
+		entry.Time = this.last
+		entry.Args = []interface{}{fmt.Sprintf("last message repeated %d times", this.repeated)}
+		repeated = &entry
+	}
+
+	this.first = nil
+	this.message = ""
+	this.repeated = 0
+	this.run++
+
+	return repeated
+}
@@ -0,0 +1,77 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package logger
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+type chanEntryWriter chan *Entry
+
+func (this chanEntryWriter) WriteEntry(entry *Entry) error {
+	this <- entry
+	return nil
+}
+
+func TestDeduplicator(t *testing.T) {
+	var buf bytes.Buffer
+
+	writer := NewLoggerWriter(&buf, ALL)
+	writer.closeFilter = true
+	writer.SetSkipCallerDepth(4)
+	writer.SetFormatter(NewTextFormatterWithFormat("%{Level} %{Message}"))
+	writer.SetDeduplicator(NewDeduplicator(time.Minute))
+
+	for i := 0; i < 4; i++ {
+		writer.Warn("same")
+	}
+	for i := 0; i < 2; i++ {
+		writer.Warn("different")
+	}
+	writer.Error("error")
+	writer.Close()
+
+	expected := "WARN same\nWARN last message repeated 3 times\nWARN different\nWARN last message repeated 1 times\nERROR error"
+	if result := strings.TrimSpace(buf.String()); result != expected {
+		t.Errorf("unexpected output %q", result)
+	}
+
+	// the run ends when the window expires
+	entries := make(chanEntryWriter, 10)
+
+	writer = NewLoggerWriter(ioutil.Discard, ALL)
+	writer.closeFilter = true
+	writer.SetSkipCallerDepth(4)
+	writer.SetFormatter(NewTextFormatterWithFormat("%{Message}"))
+	writer.SetEntryWriter(entries)
+	writer.SetDeduplicator(NewDeduplicator(50 * time.Millisecond))
+
+	for i := 0; i < 3; i++ {
+		writer.Info("same")
+	}
+
+	for _, expected := range []string{"same", "last message repeated 2 times"} {
+		select {
+		case entry := <-entries:
+			if entry.Message != expected {
+				t.Errorf("unexpected message %q", entry.Message)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for %q", expected)
+		}
+	}
+}
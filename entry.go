@@ -0,0 +1,41 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package logger
+
+import (
+	"time"
+)
+
+// Entry is a log entry passed from LoggerWriter to its output
+type Entry struct {
+	Time        time.Time
+	Level       LogLevel
+	Prefix      string
+	PackageName string
+	Function    string
+	File        string
+	Line        int
+	GoroutineID uint64 // captured only if used by a TextFormatter
+	Fields      map[string]interface{}
+	Ctx         map[string]interface{}
+	Args        []interface{} // the args passed to Write
+	Message     string        // formatted by Formatter
+
+	dumped bool // written by MemoryLogger.Dump
+}
+
+// EntryWriter writes log entries instead of log.Logger,
+// implemented by the targets that need the level or fields of entries
+type EntryWriter interface {
+	WriteEntry(entry *Entry) error
+}
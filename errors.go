@@ -0,0 +1,38 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package logger
+
+import (
+	"strings"
+)
+
+// MultiError collects several errors as one
+type MultiError []error
+
+func (this MultiError) Error() string {
+	messages := make([]string, 0, len(this))
+	for _, err := range this {
+		messages = append(messages, err.Error())
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+// Returns nil if there is no error
+func (this MultiError) ErrorOrNil() error {
+	if len(this) == 0 {
+		return nil
+	}
+
+	return this
+}
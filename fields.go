@@ -0,0 +1,300 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package logger
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// FieldsLogger writes log entries with structured fields
+// to the initialized writers, just like the package level functions
+type FieldsLogger struct {
+	fields map[string]interface{}
+	cache  atomic.Value // *fieldsWriters of the current state
+}
+
+// The writers with the fields derived from the writers of a state
+type fieldsWriters struct {
+	state   *state
+	writers []Writer
+}
+
+// Returns a FieldsLogger that adds the fields to every log entry
+func WithFields(fields map[string]interface{}) *FieldsLogger {
+	return &FieldsLogger{
+		fields: MergeFields(nil, fields),
+	}
+}
+
+// Returns a FieldsLogger with the alternating key/value pairs as fields
+func With(keyValues ...interface{}) *FieldsLogger {
+	return WithFields(KeyValues2Fields(keyValues...))
+}
+
+func (this *FieldsLogger) WithFields(fields map[string]interface{}) *FieldsLogger {
+	return &FieldsLogger{
+		fields: MergeFields(this.fields, fields),
+	}
+}
+
+func (this *FieldsLogger) With(keyValues ...interface{}) *FieldsLogger {
+	return this.WithFields(KeyValues2Fields(keyValues...))
+}
+
+// Returns the writers with the fields, derived once for every state replaced by Init or Reload
+func (this *FieldsLogger) writers() []Writer {
+	current := loadState()
+	if cached, ok := this.cache.Load().(*fieldsWriters); ok && cached.state == current {
+		return cached.writers
+	}
+
+	var writers []Writer
+	if !current.initialized {
+		writers = []Writer{DefaultConsoleLogger().WithFields(this.fields)}
+	} else {
+		writers = make([]Writer, 0, len(current.writers))
+		for _, value := range current.writers {
+			writers = append(writers, value.WithFields(this.fields))
+		}
+	}
+
+	this.cache.Store(&fieldsWriters{state: current, writers: writers})
+
+	return writers
+}
+
+func (this *FieldsLogger) Tracef(format string, args ...interface{}) {
+	for _, value := range this.writers() {
+		value.Tracef(format, args...)
+	}
+}
+
+func (this *FieldsLogger) Debugf(format string, args ...interface{}) {
+	for _, value := range this.writers() {
+		value.Debugf(format, args...)
+	}
+}
+
+func (this *FieldsLogger) Infof(format string, args ...interface{}) {
+	for _, value := range this.writers() {
+		value.Infof(format, args...)
+	}
+}
+
+func (this *FieldsLogger) Warnf(format string, args ...interface{}) {
+	for _, value := range this.writers() {
+		value.Warnf(format, args...)
+	}
+}
+
+func (this *FieldsLogger) Errorf(format string, args ...interface{}) {
+	for _, value := range this.writers() {
+		value.Errorf(format, args...)
+	}
+}
+
+func (this *FieldsLogger) Fatalf(format string, args ...interface{}) {
+	for _, value := range this.writers() {
+		value.FatalfWithExit(false, format, args...)
+	}
+	Flush()
+	os.Exit(-1)
+}
+
+func (this *FieldsLogger) Trace(args ...interface{}) {
+	for _, value := range this.writers() {
+		value.Trace(args...)
+	}
+}
+
+func (this *FieldsLogger) Debug(args ...interface{}) {
+	for _, value := range this.writers() {
+		value.Debug(args...)
+	}
+}
+
+func (this *FieldsLogger) Info(args ...interface{}) {
+	for _, value := range this.writers() {
+		value.Info(args...)
+	}
+}
+
+func (this *FieldsLogger) Warn(args ...interface{}) {
+	for _, value := range this.writers() {
+		value.Warn(args...)
+	}
+}
+
+func (this *FieldsLogger) Error(args ...interface{}) {
+	for _, value := range this.writers() {
+		value.Error(args...)
+	}
+}
+
+func (this *FieldsLogger) Fatal(args ...interface{}) {
+	for _, value := range this.writers() {
+		value.FatalWithExit(false, args...)
+	}
+	Flush()
+	os.Exit(-1)
+}
+
+func (this *FieldsLogger) TracefCtx(ctx context.Context, format string, args ...interface{}) {
+	for _, value := range this.writers() {
+		value.TracefCtx(ctx, format, args...)
+	}
+}
+
+func (this *FieldsLogger) DebugfCtx(ctx context.Context, format string, args ...interface{}) {
+	for _, value := range this.writers() {
+		value.DebugfCtx(ctx, format, args...)
+	}
+}
+
+func (this *FieldsLogger) InfofCtx(ctx context.Context, format string, args ...interface{}) {
+	for _, value := range this.writers() {
+		value.InfofCtx(ctx, format, args...)
+	}
+}
+
+func (this *FieldsLogger) WarnfCtx(ctx context.Context, format string, args ...interface{}) {
+	for _, value := range this.writers() {
+		value.WarnfCtx(ctx, format, args...)
+	}
+}
+
+func (this *FieldsLogger) ErrorfCtx(ctx context.Context, format string, args ...interface{}) {
+	for _, value := range this.writers() {
+		value.ErrorfCtx(ctx, format, args...)
+	}
+}
+
+func (this *FieldsLogger) FatalfCtx(ctx context.Context, format string, args ...interface{}) {
+	for _, value := range this.writers() {
+		value.FatalfCtxWithExit(ctx, false, format, args...)
+	}
+	Flush()
+	os.Exit(-1)
+}
+
+func (this *FieldsLogger) TraceCtx(ctx context.Context, args ...interface{}) {
+	for _, value := range this.writers() {
+		value.TraceCtx(ctx, args...)
+	}
+}
+
+func (this *FieldsLogger) DebugCtx(ctx context.Context, args ...interface{}) {
+	for _, value := range this.writers() {
+		value.DebugCtx(ctx, args...)
+	}
+}
+
+func (this *FieldsLogger) InfoCtx(ctx context.Context, args ...interface{}) {
+	for _, value := range this.writers() {
+		value.InfoCtx(ctx, args...)
+	}
+}
+
+func (this *FieldsLogger) WarnCtx(ctx context.Context, args ...interface{}) {
+	for _, value := range this.writers() {
+		value.WarnCtx(ctx, args...)
+	}
+}
+
+func (this *FieldsLogger) ErrorCtx(ctx context.Context, args ...interface{}) {
+	for _, value := range this.writers() {
+		value.ErrorCtx(ctx, args...)
+	}
+}
+
+func (this *FieldsLogger) FatalCtx(ctx context.Context, args ...interface{}) {
+	for _, value := range this.writers() {
+		value.FatalCtxWithExit(ctx, false, args...)
+	}
+	Flush()
+	os.Exit(-1)
+}
+
+// Merge fields into a new map, the values of fields override the values of base
+func MergeFields(base, fields map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base)+len(fields))
+
+	for k, v := range base {
+		merged[k] = v
+	}
+
+	for k, v := range fields {
+		merged[k] = v
+	}
+
+	return merged
+}
+
+// Convert alternating key/value pairs to fields,
+// a key without value will be set to nil
+func KeyValues2Fields(keyValues ...interface{}) map[string]interface{} {
+	fields := make(map[string]interface{}, (len(keyValues)+1)/2)
+
+	for i := 0; i < len(keyValues); i += 2 {
+		key, ok := keyValues[i].(string)
+		if !ok {
+			key = fmt.Sprint(keyValues[i])
+		}
+
+		if i+1 < len(keyValues) {
+			fields[key] = keyValues[i+1]
+		} else {
+			fields[key] = nil
+		}
+	}
+
+	return fields
+}
+
+// Convert fields to key=value pairs sorted by key,
+// values containing space, quote or equal sign will be quoted
+func Fields2String(fields map[string]interface{}) string {
+	if len(fields) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var builder strings.Builder
+	for i, k := range keys {
+		if i > 0 {
+			builder.WriteString(" ")
+		}
+
+		value := fmt.Sprintf("%v", fields[k])
+		if value == "" || strings.ContainsAny(value, " \t\r\n\"=") {
+			value = strconv.Quote(value)
+		}
+
+		builder.WriteString(k)
+		builder.WriteString("=")
+		builder.WriteString(value)
+	}
+
+	return builder.String()
+}
@@ -19,6 +19,7 @@ import (
 	"os"
 	"path"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -29,6 +30,8 @@ type FileLogger struct {
 	config     Logger
 	storeIndex int
 	storeFirst int
+	mutex      sync.Mutex // guard rolling and closing of writer
+	closed     bool
 }
 
 func NewFileLogger(level LogLevel, logFile string) (*FileLogger, error) {
@@ -155,9 +158,16 @@ func (this *FileLogger) variableReplaceBySystem(str string) string {
 }
 
 func (this *FileLogger) CheckRollingSize() {
-	// if XMLName is empty, maybe not initial from config file
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if this.closed {
+		return
+	}
+
+	// if Name is empty, maybe not initial from config file
 	// and the config maybe be empty
-	if this.config.XMLName.Local != "" {
+	if this.config.Name != "" {
 		if this.config.Rolling.SizeBased <= 0 {
 			this.config.Rolling.SizeBased = 1
 		}
@@ -166,7 +176,7 @@ func (this *FileLogger) CheckRollingSize() {
 		if err == nil {
 			// check file size
 			if fileInfo.Size() >= int64(this.config.Rolling.SizeBased)*1024*1024 {
-				this.RollingFile()
+				this.rollingFile()
 			}
 		} else {
 			Errorf("check file error with %s", err.Error())
@@ -176,6 +186,17 @@ func (this *FileLogger) CheckRollingSize() {
 
 // Rolling a new file to write logger
 func (this *FileLogger) RollingFile() {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if this.closed {
+		return
+	}
+
+	this.rollingFile()
+}
+
+func (this *FileLogger) rollingFile() {
 	var (
 		storeFileName string
 		newFileName   string
@@ -252,7 +273,8 @@ func (this *FileLogger) RollingFile() {
 		return
 	}
 
-	this.LoggerWriter.NewLogger(file)
+	// keep the log.Logger shared with the writers derived by WithFields
+	this.LoggerWriter.SetWriter(file)
 
 	this.writer.Close()
 	this.writer = file
@@ -282,6 +304,37 @@ func (this *FileLogger) RollingFile() {
 	this.keepFile()
 }
 
+// Write the queued entries, sync and close the log file,
+// wait for the in-progress rolling to finish
+func (this *FileLogger) Close() error {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if this.closed {
+		return nil
+	}
+	this.closed = true
+
+	var errs MultiError
+
+	err := this.LoggerWriter.Close()
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	err = this.writer.Sync()
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	err = this.writer.Close()
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	return errs.ErrorOrNil()
+}
+
 func (this *FileLogger) keepFile() {
 	var (
 		storeFile string
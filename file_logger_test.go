@@ -14,8 +14,15 @@
 package logger
 
 import (
+	"bufio"
 	"fmt"
 	"github.com/ronzxy/go-helper"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -39,6 +46,101 @@ func TestFileLogger(t *testing.T) {
 	t.Log("Test FileLogger finished.")
 }
 
+// Log from many goroutines while rolling by size and by time, run with -race
+func TestFileLoggerConcurrentRolling(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logger-rolling")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fileLogger, err := NewFileLoggerWithConfig(Logger{
+		Name:        "rolling",
+		FileName:    path.Join(dir, "rolling.log"),
+		FilePattern: path.Join(dir, "archive", "rolling-%{i}.log"),
+		Level:       Level{Allow: "ALL"},
+		Rolling:     Rolling{SizeBased: 1, KeepCount: 1000},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileLogger.closeFilter = true
+	fileLogger.SetSkipCallerDepth(4)
+	fileLogger.SetFormatter(NewTextFormatterWithFormat("%{Message}"))
+
+	var (
+		goroutines = 8
+		lines      = 2000
+		message    = strings.Repeat("x", 100)
+		logging    sync.WaitGroup
+		rolling    sync.WaitGroup
+		done       = make(chan struct{})
+	)
+
+	// the size check of the rolling loop and the time based rolling of the cron job
+	for _, roll := range []func(){fileLogger.CheckRollingSize, fileLogger.RollingFile} {
+		rolling.Add(1)
+		go func(roll func()) {
+			defer rolling.Done()
+
+			for {
+				select {
+				case <-done:
+					return
+				case <-time.After(5 * time.Millisecond):
+					roll()
+				}
+			}
+		}(roll)
+	}
+
+	for i := 0; i < goroutines; i++ {
+		logging.Add(1)
+		go func(i int) {
+			defer logging.Done()
+
+			for j := 0; j < lines; j++ {
+				fileLogger.Infof("%d %d %s", i, j, message)
+			}
+		}(i)
+	}
+
+	logging.Wait()
+	close(done)
+	rolling.Wait()
+
+	if err := fileLogger.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := filepath.Glob(path.Join(dir, "archive", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) == 0 {
+		t.Error("no file is rolled")
+	}
+
+	count := 0
+	for _, file := range append(files, path.Join(dir, "rolling.log")) {
+		f, err := os.Open(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			count++
+		}
+		f.Close()
+	}
+
+	if count != goroutines*lines {
+		t.Errorf("unexpected lines %d, expected %d", count, goroutines*lines)
+	}
+}
+
 func BenchmarkFileLogger(b *testing.B) {
 	DefaultConsoleLogger().SetSkipCallerDepth(4)
 	fileLogger, err := NewFileLogger(ALL, fmt.Sprintf("logs/fileLogger-bench-%s.log", helper.Time.Format("yyyy-mm-dd-HHMMSS.ns", time.Now())))
@@ -59,3 +161,26 @@ func BenchmarkFileLogger(b *testing.B) {
 		b.Log("Benchmark FileLogger finished.")
 	}
 }
+
+// go test -run=^$ -bench=FileLoggerAllocs -benchmem
+func BenchmarkFileLoggerAllocs(b *testing.B) {
+	dir, err := ioutil.TempDir("", "logger-bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fileLogger, err := NewFileLogger(ALL, path.Join(dir, "bench.log"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer fileLogger.Close()
+
+	fileLogger.closeFilter = true
+	fileLogger.SetSkipCallerDepth(4)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		fileLogger.Infof("Benchmark FileLogger %s message", "info")
+	}
+}
@@ -0,0 +1,162 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package logger
+
+import (
+	"path"
+	"strings"
+	"sync"
+)
+
+// The loggers and level of a package resolved from the filters
+type packageRoute struct {
+	filterLoggers []string        // the loggers of the matched package filters
+	loggers       map[string]bool // the loggers written, including the default loggers if additive
+	level         LogLevel
+}
+
+// Returns whether the entry of level is written by the logger name,
+// the level set by SetPackageLevel is not considered
+func (this *packageRoute) allows(name string, level LogLevel) bool {
+	return level >= this.level && this.loggers[name]
+}
+
+// The routes resolved for the packages of config, cleared when config is changed
+var routeCache = struct {
+	sync.RWMutex
+	config *Config
+	routes map[string]*packageRoute
+}{}
+
+// Returns the route of the package by the filters of c
+func getPackageRoute(c *Config, packageName string) *packageRoute {
+	routeCache.RLock()
+	route := routeCache.routes[packageName]
+	cached := routeCache.config == c
+	routeCache.RUnlock()
+
+	if cached && route != nil {
+		return route
+	}
+
+	route = c.route(packageName)
+
+	routeCache.Lock()
+	if routeCache.config != c {
+		routeCache.config = c
+		routeCache.routes = map[string]*packageRoute{}
+	}
+	routeCache.routes[packageName] = route
+	routeCache.Unlock()
+
+	return route
+}
+
+// Resolve the route of the package, the package filters with the most specific name
+// are applied. A matched filter without loggers drops all entries of the package
+func (this *Config) route(packageName string) *packageRoute {
+	var (
+		route    = &packageRoute{loggers: map[string]bool{}}
+		matched  []Filter
+		best     = -1
+		additive = true
+	)
+
+	for _, filter := range this.PackageFilters {
+		score, _ := matchPackage(filter.Name, packageName)
+		if score < 0 || score < best {
+			continue
+		}
+
+		if score > best {
+			best = score
+			matched = nil
+		}
+		matched = append(matched, filter)
+	}
+
+	route.level = OFF
+	for _, filter := range matched {
+		route.filterLoggers = append(route.filterLoggers, filter.Loggers...)
+
+		level := LogLevel(ALL)
+		if filter.Level != "" {
+			level = ConvertString2Level(filter.Level)
+		}
+		if level < route.level {
+			route.level = level
+		}
+
+		additive = additive && filter.Additive()
+	}
+
+	if matched == nil {
+		route.level = ALL
+	} else if len(route.filterLoggers) == 0 {
+		// No Logger define
+		return route
+	}
+
+	for _, name := range route.filterLoggers {
+		route.loggers[name] = true
+	}
+
+	if additive {
+		for _, name := range this.DefaultFilter.Loggers {
+			route.loggers[name] = true
+		}
+	}
+
+	return route
+}
+
+// Returns the specificity of the match of the filter name to the package, -1 if not matched.
+// The name matches the package and its sub packages, and a segment of the name
+// may be a glob pattern matching a segment of the package. The names of more segments
+// are more specific, then the names of less patterns
+func matchPackage(name, packageName string) (int, error) {
+	var (
+		patterns  = strings.Split(name, "/")
+		segments  = strings.Split(packageName, "/")
+		matched   = len(segments) >= len(patterns)
+		wildcards int
+	)
+
+	for i, pattern := range patterns {
+		var segment string
+		if i < len(segments) {
+			segment = segments[i]
+		}
+
+		if !strings.ContainsAny(pattern, `*?[\`) {
+			matched = matched && pattern == segment
+			continue
+		}
+
+		wildcards++
+
+		// the pattern is checked even if not matched
+		ok, err := path.Match(pattern, segment)
+		if err != nil {
+			return -1, err
+		}
+		matched = matched && ok
+	}
+
+	if !matched {
+		return -1, nil
+	}
+
+	// the wildcards are not more than the patterns, so the names of more segments come first
+	return len(patterns)*(len(patterns)+1) - wildcards, nil
+}
@@ -0,0 +1,115 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package logger
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestPackageRoute(t *testing.T) {
+	additivity := false
+
+	c := &Config{
+		DefaultFilter: Filter{Loggers: []string{"default"}},
+		PackageFilters: []Filter{
+			{Name: "github.com/us/app", Loggers: []string{"app"}},
+			{Name: "github.com/us/app/db", Level: "WARN", Additivity: &additivity, Loggers: []string{"db"}},
+			{Name: "github.com/us/*/internal", Loggers: []string{"internal"}},
+			{Name: "github.com/us/muted"},
+		},
+	}
+
+	for packageName, expected := range map[string]string{
+		"github.com/us/app":                 "ALL app,default",
+		"github.com/us/app/http":            "ALL app,default",
+		"github.com/us/app/db":              "WARN db",
+		"github.com/us/app/db/sql":          "WARN db",
+		"github.com/us/app/internal":        "ALL default,internal",
+		"github.com/us/svc/internal/client": "ALL default,internal",
+		"github.com/us/svc":                 "ALL default",
+		"github.com/us/muted/sub":           "ALL ",
+		"github.com/us/application":         "ALL default",
+		"main":                              "ALL default",
+	} {
+		route := c.route(packageName)
+
+		var loggers []string
+		for name := range route.loggers {
+			loggers = append(loggers, name)
+		}
+		sort.Strings(loggers)
+
+		if result := ConvertLevel2String(route.level) + " " + strings.Join(loggers, ","); result != expected {
+			t.Errorf("unexpected route of %s: %s", packageName, result)
+		}
+	}
+
+	if route := getPackageRoute(c, "github.com/us/app/db"); !route.allows("db", ERROR) || route.allows("db", INFO) || route.allows("default", ERROR) {
+		t.Error("unexpected allows")
+	}
+
+	_, err := NewConfigBuilder().
+		ConsoleLogger("console").
+		Filter(Filter{Name: "github.com/us/[", Loggers: []string{"console"}}).
+		Filter(Filter{Name: "github.com/us/app", Level: "VERBOSE", Loggers: []string{"console"}}).
+		Build()
+	if err == nil || !strings.Contains(err.Error(), `invalid package pattern "github.com/us/["`) || !strings.Contains(err.Error(), `unknown level "VERBOSE"`) {
+		t.Errorf("unexpected build error: %v", err)
+	}
+}
+
+// The level set at runtime takes precedence over the level of the filter, both ways
+func TestPackageFilterRuntimeLevel(t *testing.T) {
+	_, restore := isolateState(t)
+	defer restore()
+	defer ResetPackageLevel("github.com/ronzxy/go-logger")
+
+	c, err := NewConfigBuilder().
+		MemoryLogger("memory", 10).
+		Level("ALL", "").
+		Format("text", "%{Level} %{Message}").
+		DefaultFilter("memory").
+		Filter(Filter{Name: "github.com/ronzxy/go-logger", Level: "INFO", Loggers: []string{"memory"}}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := InitWithConfig(c); err != nil {
+		t.Fatal(err)
+	}
+
+	Debug("debug " + strconv.Itoa(1))
+
+	SetPackageLevel("github.com/ronzxy/go-logger", TRACE)
+	Debug("debug " + strconv.Itoa(2))
+
+	SetPackageLevel("github.com/ronzxy/go-logger", ERROR)
+	Warn("warn " + strconv.Itoa(3))
+
+	ResetPackageLevel("github.com/ronzxy/go-logger")
+	Debug("debug " + strconv.Itoa(4))
+	Warn("warn " + strconv.Itoa(5))
+
+	var messages []string
+	for _, entry := range GetWriter("memory").(*MemoryLogger).Snapshot() {
+		messages = append(messages, strings.TrimSpace(entry.Message))
+	}
+
+	if result := strings.Join(messages, ","); result != "DEBUG debug 2,WARN warn 5" {
+		t.Errorf("unexpected messages %q", result)
+	}
+}
@@ -12,6 +12,72 @@
 
 package logger
 
+import (
+	"bytes"
+	"sync"
+	"time"
+)
+
+// Formatter formats a record into the written message.
+// The record is reused after Message returns, it must not be kept by the formatter
 type Formatter interface {
-	Message(data map[string]interface{}, args ...interface{}) string
+	Message(record *Record) string
+}
+
+// Record is the data of an entry passed to Formatter, taken from a pool
+type Record struct {
+	Time        time.Time
+	Level       LogLevel
+	Prefix      string
+	PackageName string
+	Function    string
+	File        string
+	Line        int
+	GoroutineID uint64
+	Fields      map[string]interface{}
+	Ctx         map[string]interface{}
+	Args        []interface{}
+
+	buffer *bytes.Buffer // reused by TextFormatter
+}
+
+const (
+	// the buffer of a larger message is not kept by the pool
+	maxRecordBufferSize = 64 * 1024
+)
+
+var (
+	recordPool = sync.Pool{
+		New: func() interface{} {
+			return &Record{buffer: &bytes.Buffer{}}
+		},
+	}
+)
+
+// Returns a record of the entry from the pool, put back by release
+func newRecord(entry *Entry) *Record {
+	record := recordPool.Get().(*Record)
+	record.Time = entry.Time
+	record.Level = entry.Level
+	record.Prefix = entry.Prefix
+	record.PackageName = entry.PackageName
+	record.Function = entry.Function
+	record.File = entry.File
+	record.Line = entry.Line
+	record.GoroutineID = entry.GoroutineID
+	record.Fields = entry.Fields
+	record.Ctx = entry.Ctx
+	record.Args = entry.Args
+
+	return record
+}
+
+func (this *Record) release() {
+	if this.buffer.Cap() > maxRecordBufferSize {
+		return
+	}
+
+	this.buffer.Reset()
+	*this = Record{buffer: this.buffer}
+	recordPool.Put(this)
 }
@@ -3,7 +3,9 @@ module github.com/ronzxy/go-logger
 go 1.13
 
 require (
+	github.com/BurntSushi/toml v0.3.0
 	github.com/robfig/cron v1.2.0
 	github.com/ronzxy/go-helper v0.0.0-20191013041235-792ac5c0b6e3
+	gopkg.in/yaml.v2 v2.4.0
 	xorm.io/core v0.7.3
 )
@@ -0,0 +1,266 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Body formats of the batches posted by HTTPLogger
+const (
+	// A JSON array of entries
+	BatchFormatJSON = "json"
+	// An entry per line
+	BatchFormatNDJSON = "ndjson"
+
+	DefaultRetries = 3
+)
+
+var (
+	batchFormats = []string{"", BatchFormatJSON, BatchFormatNDJSON}
+
+	httpTimeout = 10 * time.Second
+	// the backoff of retrying doubles from httpMinBackoff
+	httpMinBackoff = time.Second
+)
+
+// HTTPLogger posts the formatted entries to url in batches,
+// a batch is posted when it is full, an entry of the flush level (ERROR by default) is added
+// or every flush interval, and retried with backoff if failed
+type HTTPLogger struct {
+	*LoggerWriter
+
+	url         string
+	header      http.Header
+	batchFormat string
+	retries     int
+	client      *http.Client
+	mutex       sync.Mutex // guard header, batchFormat and retries
+	batch       *BatchWriter
+}
+
+func NewHTTPLogger(level LogLevel, url string) *HTTPLogger {
+	httpLogger := &HTTPLogger{
+		url:         url,
+		header:      http.Header{},
+		batchFormat: BatchFormatJSON,
+		retries:     DefaultRetries,
+		client:      &http.Client{Timeout: httpTimeout},
+	}
+
+	httpLogger.batch = NewBatchWriter(DefaultBatchSize, DefaultFlushInterval*time.Millisecond, httpLogger.post)
+	httpLogger.batch.SetFlushLevel(ERROR)
+	httpLogger.LoggerWriter = NewLoggerWriter(ioutil.Discard, level)
+	httpLogger.SetEntryWriter(httpLogger)
+	httpLogger.exitFlush = httpLogger.Flush
+
+	return httpLogger
+}
+
+func NewHTTPLoggerWithConfig(v Logger) *HTTPLogger {
+	httpLogger := NewHTTPLogger(ConvertString2Level(v.Level.Allow), VariableReplaceByConfig(v.URL))
+	httpLogger.SetDenyLevel(ConvertString2Level(v.Level.Deny))
+
+	for _, header := range v.Headers {
+		httpLogger.SetHeader(header.Name, VariableReplaceByConfig(header.Value))
+	}
+
+	if v.BatchFormat != "" {
+		httpLogger.SetBatchFormat(v.BatchFormat)
+	}
+
+	if v.BatchSize > 0 || v.FlushInterval > 0 {
+		httpLogger.SetBatch(v.BatchSize, time.Duration(v.FlushInterval)*time.Millisecond)
+	}
+
+	if v.FlushLevel != "" {
+		httpLogger.SetFlushLevel(ConvertString2Level(v.FlushLevel))
+	}
+
+	if v.Retries != 0 {
+		httpLogger.SetRetries(v.Retries)
+	}
+
+	return httpLogger
+}
+
+// Set the request header, such as Authorization
+func (this *HTTPLogger) SetHeader(name, value string) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.header.Set(name, value)
+}
+
+// Set the body format, json or ndjson
+func (this *HTTPLogger) SetBatchFormat(batchFormat string) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.batchFormat = strings.ToLower(batchFormat)
+}
+
+// Set the max entries of a batch and the interval of posting the batch not full,
+// the default value is used if it is not positive
+func (this *HTTPLogger) SetBatch(batchSize int, flushInterval time.Duration) {
+	this.batch.SetBatch(batchSize, flushInterval)
+}
+
+// Post the pending batch immediately when an entry of level or higher is written, OFF disables it
+func (this *HTTPLogger) SetFlushLevel(level LogLevel) {
+	this.batch.SetFlushLevel(level)
+}
+
+// Set the retry count of a failed batch, negative means no retry
+func (this *HTTPLogger) SetRetries(retries int) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.retries = retries
+}
+
+// Implement interface EntryWriter
+func (this *HTTPLogger) WriteEntry(entry *Entry) error {
+	return this.batch.Write(entry)
+}
+
+// Post the pending entries
+func (this *HTTPLogger) Flush() error {
+	err := this.LoggerWriter.Flush()
+	if err != nil {
+		return err
+	}
+
+	return this.batch.Flush()
+}
+
+// Post the pending entries and stop the background goroutine
+func (this *HTTPLogger) Close() error {
+	err := this.LoggerWriter.Close()
+
+	if e := this.batch.Close(); err == nil {
+		err = e
+	}
+
+	return err
+}
+
+// Post the batch, retry with backoff if failed
+func (this *HTTPLogger) post(batch []*Entry) error {
+	this.mutex.Lock()
+	var (
+		header      = http.Header{}
+		batchFormat = this.batchFormat
+		retries     = this.retries
+	)
+	for name, values := range this.header {
+		header[name] = values
+	}
+	this.mutex.Unlock()
+
+	var (
+		body    = this.body(batchFormat, batch)
+		backoff = httpMinBackoff
+		err     error
+	)
+
+	for i := 0; ; i++ {
+		var retry bool
+
+		retry, err = this.request(header, batchFormat, body)
+		if err == nil || !retry || i >= retries {
+			return err
+		}
+
+		select {
+		case <-this.batch.Closing():
+			// post the rest batches as soon as possible when closing
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// Returns whether the request can be retried if failed
+func (this *HTTPLogger) request(header http.Header, batchFormat string, body []byte) (bool, error) {
+	request, err := http.NewRequest(http.MethodPost, this.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+
+	if batchFormat == BatchFormatNDJSON {
+		request.Header.Set("Content-Type", "application/x-ndjson")
+	} else {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	for name, values := range header {
+		request.Header[name] = values
+	}
+
+	response, err := this.client.Do(request)
+	if err != nil {
+		return true, err
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, response.Body)
+
+	if response.StatusCode >= 300 {
+		retry := response.StatusCode >= 500 || response.StatusCode == http.StatusTooManyRequests
+		return retry, fmt.Errorf("post logs to %s: %s", this.url, response.Status)
+	}
+
+	return false, nil
+}
+
+// The entries formatted by JSONFormatter are kept, others are quoted as JSON string
+func (this *HTTPLogger) body(batchFormat string, batch []*Entry) []byte {
+	var buf bytes.Buffer
+
+	if batchFormat != BatchFormatNDJSON {
+		buf.WriteByte('[')
+	}
+
+	for i, entry := range batch {
+		message := strings.TrimRight(entry.Message, "\n")
+
+		if i > 0 && batchFormat != BatchFormatNDJSON {
+			buf.WriteByte(',')
+		}
+
+		if strings.HasPrefix(message, "{") && json.Valid([]byte(message)) {
+			buf.WriteString(message)
+		} else {
+			data, _ := json.Marshal(message)
+			buf.Write(data)
+		}
+
+		if batchFormat == BatchFormatNDJSON {
+			buf.WriteByte('\n')
+		}
+	}
+
+	if batchFormat != BatchFormatNDJSON {
+		buf.WriteByte(']')
+	}
+
+	return buf.Bytes()
+}
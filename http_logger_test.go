@@ -0,0 +1,198 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package logger
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHTTPLogger(t *testing.T) {
+	defer func(backoff time.Duration) {
+		httpMinBackoff = backoff
+	}(httpMinBackoff)
+	httpMinBackoff = 10 * time.Millisecond
+
+	var (
+		mutex    sync.Mutex
+		requests int
+		bodies   []string
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		requests++
+		if requests == 1 {
+			// the first batch is retried
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		if r.Header.Get("Authorization") != "Bearer token" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected headers %v", r.Header)
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+	}))
+	defer server.Close()
+
+	httpLogger := NewHTTPLoggerWithConfig(Logger{
+		URL:           server.URL,
+		BatchSize:     2,
+		FlushInterval: 60000,
+		FlushLevel:    "OFF",
+		Headers:       []Header{{Name: "Authorization", Value: "Bearer token"}},
+		Level:         Level{Allow: "ERROR"},
+	})
+	httpLogger.closeFilter = true
+	httpLogger.SetSkipCallerDepth(4)
+	httpLogger.SetFormatter(NewTextFormatterWithFormat("%{Level} %{Message}"))
+
+	httpLogger.Info("ignored")
+	httpLogger.Error("error 1")
+	httpLogger.Write(FATAL, "fatal 2")
+	httpLogger.Error(`error "3"`)
+	httpLogger.Close()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if requests != 3 || len(bodies) != 2 {
+		t.Fatalf("unexpected requests %d", requests)
+	}
+
+	if bodies[0] != `["ERROR error 1","FATAL fatal 2"]` || bodies[1] != `["ERROR error \"3\""]` {
+		t.Errorf("unexpected bodies %q", bodies)
+	}
+}
+
+func TestHTTPLoggerNDJSON(t *testing.T) {
+	bodies := make(chan string, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies <- r.Header.Get("Content-Type") + "|" + string(body)
+	}))
+	defer server.Close()
+
+	httpLogger := NewHTTPLogger(ALL, server.URL)
+	defer httpLogger.Close()
+
+	httpLogger.closeFilter = true
+	httpLogger.SetSkipCallerDepth(4)
+	httpLogger.SetFormatter(NewTextFormatterWithFormat(`{"message":"%{Message}"}`))
+	httpLogger.SetBatchFormat(BatchFormatNDJSON)
+	httpLogger.SetBatch(10, 10*time.Millisecond)
+
+	httpLogger.Warn("a")
+	httpLogger.Warn("b")
+
+	select {
+	case body := <-bodies:
+		if body != "application/x-ndjson|{\"message\":\"a\"}\n{\"message\":\"b\"}\n" {
+			t.Errorf("unexpected body %q", body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no batch posted in flush interval")
+	}
+}
+
+// An ERROR entry is posted without waiting for the batch or the flush interval
+func TestHTTPLoggerFlushLevel(t *testing.T) {
+	bodies := make(chan string, 10)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies <- string(body)
+	}))
+	defer server.Close()
+
+	httpLogger := NewHTTPLoggerWithConfig(Logger{
+		URL:           server.URL,
+		BatchSize:     100,
+		FlushInterval: 60000,
+		Level:         Level{Allow: "ALL"},
+	})
+	defer httpLogger.Close()
+
+	httpLogger.closeFilter = true
+	httpLogger.SetFormatter(NewTextFormatterWithFormat("%{Level} %{Message}"))
+
+	httpLogger.Write(INFO, "info")
+	httpLogger.Write(ERROR, "error")
+
+	select {
+	case body := <-bodies:
+		if body != `["INFO info","ERROR error"]` {
+			t.Errorf("unexpected body %s", body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the error entry is not posted immediately")
+	}
+}
+
+// The pending batch is posted before Fatal exits
+func TestHTTPLoggerFatalExit(t *testing.T) {
+	if url := os.Getenv("LOGGER_TEST_HTTP_URL"); url != "" {
+		httpLogger := NewHTTPLoggerWithConfig(Logger{
+			URL:           url,
+			BatchSize:     100,
+			FlushInterval: 60000,
+			FlushLevel:    "OFF",
+			Level:         Level{Allow: "ALL"},
+		})
+
+		httpLogger.closeFilter = true
+		httpLogger.SetFormatter(NewTextFormatterWithFormat("%{Level} %{Message}"))
+
+		httpLogger.Write(INFO, "info")
+		httpLogger.FatalWithExit(true, "fatal")
+
+		return
+	}
+
+	bodies := make(chan string, 10)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies <- string(body)
+	}))
+	defer server.Close()
+
+	cmd := exec.Command(os.Args[0], "-test.run", "^TestHTTPLoggerFatalExit$")
+	cmd.Env = append(os.Environ(), "LOGGER_TEST_HTTP_URL="+server.URL)
+
+	if err := cmd.Run(); err == nil {
+		t.Error("unexpected exit without error")
+	} else if e, ok := err.(*exec.ExitError); !ok || e.ExitCode() != 1 {
+		t.Errorf("unexpected exit %v", err)
+	}
+
+	select {
+	case body := <-bodies:
+		if body != `["INFO info","FATAL fatal"]` {
+			t.Errorf("unexpected body %s", body)
+		}
+	default:
+		t.Error("the pending batch is not posted before exit")
+	}
+}
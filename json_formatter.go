@@ -14,11 +14,49 @@ package logger
 
 import (
 	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
 )
 
+const (
+	// The time formats of JSONFormatter besides the layouts like yyyy-mm-dd HH:MM:SS
+	JSONTimeRFC3339     = "rfc3339"
+	JSONTimeRFC3339Nano = "rfc3339nano"
+	JSONTimeMillis      = "millis" // milliseconds since epoch
+	JSONTimeNanos       = "nanos"  // nanoseconds since epoch
+
+	// The caller info is rendered as top level keys or as an object
+	JSONCallerFlat   = "flat"
+	JSONCallerNested = "nested"
+)
+
+var (
+	// The builtin keys of JSONFormatter, an empty key is omitted
+	defaultJSONKeys = map[string]string{
+		"Prefix":      "Prefix",
+		"Time":        "Time",
+		"Level":       "Level",
+		"Message":     "Message",
+		"PackageName": "PackageName",
+		"File":        "File",
+		"Line":        "Line",
+		"Function":    "",
+		"GoroutineID": "",
+		"Ctx":         "Ctx",
+		"Caller":      "caller",
+	}
+	jsonLevelCases = []string{"", "upper", "lower"}
+	jsonCallers    = []string{"", JSONCallerFlat, JSONCallerNested}
+)
+
 type JSONFormatter struct {
-	Indent bool
+	Indent     bool
+	TimeFormat string                 // a JSONTime* constant or a layout, DefaultLogTimeFormat if empty
+	LowerLevel bool                   // render the level as "info" instead of "INFO"
+	NestCaller bool                   // render PackageName, File, Line and Function under the Caller key
+	Keys       map[string]string      // the keys of the builtin names, "-" omits the value
+	Extra      map[string]interface{} // static values added to every entry
 }
 
 func NewJSONFormatter() *JSONFormatter {
@@ -27,29 +65,221 @@ func NewJSONFormatter() *JSONFormatter {
 	}
 }
 
-func (this *JSONFormatter) Message(data map[string]interface{}, args ...interface{}) string {
+// Returns the JSONFormatter of the schema in the Format of the logger
+func NewJSONFormatterWithConfig(v Logger) *JSONFormatter {
+	this := NewJSONFormatter()
+	this.Indent = v.Format.Indent
+	this.TimeFormat = v.Format.TimeFormat
+	this.LowerLevel = strings.EqualFold(v.Format.LevelCase, "lower")
+	this.NestCaller = strings.EqualFold(v.Format.Caller, JSONCallerNested)
+
+	keys, err := parseJSONKeys(v.Format.Keys)
+	if err != nil {
+		DefaultConsoleLogger().Errorf("logger %s: %s", v.Name, err.Error())
+	}
+	this.Keys = keys
+
+	extra, err := parsePairs(VariableReplaceByConfig(v.Format.Extra))
+	if err != nil {
+		DefaultConsoleLogger().Errorf("logger %s: %s", v.Name, err.Error())
+	}
+	for k, v := range extra {
+		if this.Extra == nil {
+			this.Extra = map[string]interface{}{}
+		}
+		this.Extra[k] = v
+	}
+
+	return this
+}
+
+func (this *JSONFormatter) Message(record *Record) string {
 	var (
-		buf []byte
-		err error
+		buf  []byte
+		err  error
+		data = make(map[string]interface{}, 12)
+		args = record.Args
 	)
 
-	data["Time"] = time.Now().Format(DefaultLogTimeFormat)
+	this.put(data, "Prefix", record.Prefix)
+	this.put(data, "Time", this.time(record.Time))
+
+	level := ConvertLevel2String(record.Level)
+	if this.LowerLevel {
+		level = strings.ToLower(level)
+	}
+	this.put(data, "Level", level)
+
+	caller := data
+	if this.NestCaller {
+		caller = make(map[string]interface{}, 4)
+	}
+	this.put(caller, "PackageName", record.PackageName)
+	this.put(caller, "File", record.File)
+	this.put(caller, "Line", record.Line)
+	this.put(caller, "Function", record.Function)
+	if this.NestCaller && len(caller) > 0 {
+		this.put(data, "Caller", caller)
+	}
+
+	this.put(data, "GoroutineID", record.GoroutineID)
+	if len(record.Ctx) > 0 {
+		this.put(data, "Ctx", record.Ctx)
+	}
+
 	switch len(args) {
 	case 1:
-		data["Message"] = args[0]
+		this.put(data, "Message", args[0])
 	default:
-		data["Message"] = args
+		this.put(data, "Message", args)
 	}
 
-	if this.Indent {
-		buf, err = json.MarshalIndent(data, "", "")
-	} else {
-		buf, err = json.Marshal(data)
+	// render structured fields as top level keys,
+	// a field with the same name as a builtin key is prefixed by "fields."
+	for k, v := range record.Fields {
+		if _, exist := data[k]; exist {
+			k = "fields." + k
+		}
+		data[k] = v
 	}
 
+	for k, v := range this.Extra {
+		if _, exist := data[k]; !exist {
+			data[k] = v
+		}
+	}
+
+	buf, err = this.marshal(data)
 	if err != nil {
-		Errorf("")
+		// render the values can not be marshaled with %+v instead of losing the entry
+		for k, v := range data {
+			data[k] = jsonSafeValue(v)
+		}
+
+		buf, err = this.marshal(data)
+		if err != nil {
+			return fmt.Sprintf("%+v", data)
+		}
 	}
 
 	return string(buf)
 }
+
+func (this *JSONFormatter) marshal(data map[string]interface{}) ([]byte, error) {
+	if this.Indent {
+		return json.MarshalIndent(data, "", "  ")
+	}
+
+	return json.Marshal(data)
+}
+
+// Set the value of the builtin name with its key, omitted if the key is empty
+func (this *JSONFormatter) put(data map[string]interface{}, name string, value interface{}) {
+	key, ok := this.Keys[name]
+	if !ok {
+		key = defaultJSONKeys[name]
+	}
+
+	if key != "" && key != "-" {
+		data[key] = value
+	}
+}
+
+func (this *JSONFormatter) time(t time.Time) interface{} {
+	if t.IsZero() {
+		t = time.Now()
+	}
+
+	switch strings.ToLower(this.TimeFormat) {
+	case "":
+		{
+			return t.Format(DefaultLogTimeFormat)
+		}
+	case JSONTimeRFC3339:
+		{
+			return t.Format(time.RFC3339)
+		}
+	case JSONTimeRFC3339Nano:
+		{
+			return t.Format(time.RFC3339Nano)
+		}
+	case JSONTimeMillis:
+		{
+			return t.UnixNano() / int64(time.Millisecond)
+		}
+	case JSONTimeNanos:
+		{
+			return t.UnixNano()
+		}
+	default:
+		{
+			return t.Format(timeLayout(this.TimeFormat))
+		}
+	}
+}
+
+// Returns the value, or %+v of it if it can not be marshaled
+func jsonSafeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		{
+			safe := make(map[string]interface{}, len(v))
+			for k, item := range v {
+				safe[k] = jsonSafeValue(item)
+			}
+
+			return safe
+		}
+	case []interface{}:
+		{
+			safe := make([]interface{}, len(v))
+			for i, item := range v {
+				safe[i] = jsonSafeValue(item)
+			}
+
+			return safe
+		}
+	}
+
+	if _, err := json.Marshal(value); err != nil {
+		return fmt.Sprintf("%+v", value)
+	}
+
+	return value
+}
+
+// Parse the keys of the builtin names like "Message=msg,Time=ts,Prefix=-"
+func parseJSONKeys(str string) (map[string]string, error) {
+	keys, err := parsePairs(str)
+	if err != nil {
+		return nil, err
+	}
+
+	for name := range keys {
+		if _, ok := defaultJSONKeys[name]; !ok {
+			return keys, fmt.Errorf("unknown json key %q", name)
+		}
+	}
+
+	return keys, nil
+}
+
+// Parse the comma separated name=value pairs
+func parsePairs(str string) (map[string]string, error) {
+	if strings.TrimSpace(str) == "" {
+		return nil, nil
+	}
+
+	pairs := map[string]string{}
+	for _, pair := range strings.Split(str, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		name := strings.TrimSpace(kv[0])
+		if len(kv) != 2 || name == "" {
+			return pairs, fmt.Errorf("invalid pair %q, expected name=value", strings.TrimSpace(pair))
+		}
+
+		pairs[name] = strings.TrimSpace(kv[1])
+	}
+
+	return pairs, nil
+}
@@ -0,0 +1,82 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package logger
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONFormatter(t *testing.T) {
+	c, err := NewConfigBuilder().
+		MemoryLogger("memory", 10).
+		JSONFormat(Format{
+			TimeFormat: JSONTimeMillis,
+			LevelCase:  "lower",
+			Caller:     JSONCallerNested,
+			Keys:       "Message=msg,Prefix=-,Function=func,Caller=src",
+			Extra:      "service=api",
+		}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		formatter = NewJSONFormatterWithConfig(c.Loggers[0])
+		record    = newRecord(&Entry{
+			Time:        time.Unix(1500000000, 123456789),
+			Level:       WARN,
+			Prefix:      "app",
+			PackageName: "main",
+			Function:    "main.main",
+			File:        "main.go",
+			Line:        10,
+			Fields:      map[string]interface{}{"service": "shadowed", "ch": make(chan int)},
+			Args:        []interface{}{"warn"},
+		})
+	)
+	defer record.release()
+
+	// the channel can not be marshaled, it is rendered with %+v
+	if message := formatter.Message(record); message != `{"Level":"warn","Time":1500000000123,"ch":"`+formatChan(record)+`","msg":"warn","service":"shadowed","src":{"File":"main.go","Line":10,"PackageName":"main","func":"main.main"}}` {
+		t.Errorf("unexpected message %s", message)
+	}
+
+	record.Fields = nil
+	if message := formatter.Message(record); !strings.Contains(message, `"service":"api"`) {
+		t.Errorf("unexpected message %s", message)
+	}
+	record.Fields = map[string]interface{}{"service": "shadowed", "ch": make(chan int)}
+
+	formatter = NewJSONFormatter()
+	formatter.TimeFormat = JSONTimeRFC3339
+	formatter.Keys = map[string]string{"PackageName": "-", "File": "-", "Line": "-"}
+	if message := formatter.Message(record); message != `{"Level":"WARN","Message":"warn","Prefix":"app","Time":"`+record.Time.Format(time.RFC3339)+`","ch":"`+formatChan(record)+`","service":"shadowed"}` {
+		t.Errorf("unexpected message %s", message)
+	}
+
+	_, err = NewConfigBuilder().
+		MemoryLogger("memory", 10).
+		JSONFormat(Format{LevelCase: "title", Caller: "deep", Keys: "Msg=msg", Extra: "service"}).
+		Build()
+	if err == nil || !strings.Contains(err.Error(), `unknown level case "title"`) || !strings.Contains(err.Error(), `unknown caller "deep"`) ||
+		!strings.Contains(err.Error(), `unknown json key "Msg"`) || !strings.Contains(err.Error(), `invalid pair "service"`) {
+		t.Errorf("unexpected build error: %v", err)
+	}
+}
+
+func formatChan(record *Record) string {
+	return jsonSafeValue(record.Fields["ch"]).(string)
+}
@@ -0,0 +1,217 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package logger
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The level of a package set at runtime
+type packageLevel struct {
+	level    LogLevel
+	expires  time.Time // zero if never expires
+	timer    *time.Timer
+	previous *packageLevel // restored when expired
+}
+
+// PackageLevel describes a level set by SetPackageLevel
+type PackageLevel struct {
+	Package string    `json:"package"`
+	Level   string    `json:"level"`
+	Expires time.Time `json:"expires,omitempty"`
+}
+
+// LoggerLevel describes the levels of an initialized logger
+type LoggerLevel struct {
+	Name   string `json:"name"`
+	Target string `json:"target"`
+	Allow  string `json:"allow"`
+	Deny   string `json:"deny"`
+}
+
+var (
+	packageLevelMutex sync.RWMutex
+	packageLevels     = map[string]*packageLevel{}
+	packageLevelCount int32 // checked before locking by the writers
+)
+
+// Change the allow and deny levels of the initialized logger name at runtime
+func SetLevel(loggerName string, allow, deny LogLevel) error {
+	writer := GetWriter(loggerName)
+	if writer == nil {
+		return fmt.Errorf("undefined logger %s", loggerName)
+	}
+
+	writer.SetLevels(allow, deny)
+
+	return nil
+}
+
+// Returns the levels of the initialized loggers sorted by name
+func GetLevels() []LoggerLevel {
+	var (
+		levels []LoggerLevel
+		s      = loadState()
+	)
+
+	if !s.initialized {
+		return levels
+	}
+
+	for name, writer := range s.writers {
+		var (
+			allow, deny = writer.Levels()
+			target      string
+		)
+
+		if v := findLogger(s.config, name); v != nil {
+			target = v.Target
+		}
+
+		levels = append(levels, LoggerLevel{
+			Name:   name,
+			Target: target,
+			Allow:  ConvertLevel2String(allow),
+			Deny:   ConvertLevel2String(deny),
+		})
+	}
+
+	sort.Slice(levels, func(i, j int) bool {
+		return levels[i].Name < levels[j].Name
+	})
+
+	return levels
+}
+
+// Set the level of the entries written from the package and its sub packages,
+// it overrides the allow level of every logger and the level of the package filters.
+// The deny levels of the loggers still apply
+func SetPackageLevel(packageName string, level LogLevel) {
+	setPackageLevel(packageName, level, 0)
+}
+
+// Set the level of the package as SetPackageLevel, the previous level is restored after ttl
+func SetPackageLevelWithTTL(packageName string, level LogLevel, ttl time.Duration) {
+	setPackageLevel(packageName, level, ttl)
+}
+
+// Remove the level set for the package
+func ResetPackageLevel(packageName string) {
+	packageLevelMutex.Lock()
+	defer packageLevelMutex.Unlock()
+
+	if current := packageLevels[packageName]; current != nil && current.timer != nil {
+		current.timer.Stop()
+	}
+
+	delete(packageLevels, packageName)
+	atomic.StoreInt32(&packageLevelCount, int32(len(packageLevels)))
+}
+
+// Returns the levels set for packages sorted by package name
+func GetPackageLevels() []PackageLevel {
+	packageLevelMutex.RLock()
+	defer packageLevelMutex.RUnlock()
+
+	var levels []PackageLevel
+	for name, v := range packageLevels {
+		levels = append(levels, PackageLevel{
+			Package: name,
+			Level:   ConvertLevel2String(v.level),
+			Expires: v.expires,
+		})
+	}
+
+	sort.Slice(levels, func(i, j int) bool {
+		return levels[i].Package < levels[j].Package
+	})
+
+	return levels
+}
+
+func setPackageLevel(packageName string, level LogLevel, ttl time.Duration) {
+	packageLevelMutex.Lock()
+	defer packageLevelMutex.Unlock()
+
+	// a temporary level replaced by another one still restores the level before it
+	var previous *packageLevel
+	if current := packageLevels[packageName]; current != nil {
+		if current.timer != nil {
+			current.timer.Stop()
+			previous = current.previous
+		} else {
+			previous = current
+		}
+	}
+
+	v := &packageLevel{level: level}
+	if ttl > 0 {
+		v.expires = time.Now().Add(ttl)
+		v.previous = previous
+		v.timer = time.AfterFunc(ttl, func() {
+			revertPackageLevel(packageName, v)
+		})
+	}
+
+	packageLevels[packageName] = v
+	atomic.StoreInt32(&packageLevelCount, int32(len(packageLevels)))
+}
+
+func revertPackageLevel(packageName string, v *packageLevel) {
+	packageLevelMutex.Lock()
+	defer packageLevelMutex.Unlock()
+
+	if packageLevels[packageName] != v {
+		return
+	}
+
+	if v.previous != nil {
+		packageLevels[packageName] = v.previous
+	} else {
+		delete(packageLevels, packageName)
+	}
+
+	atomic.StoreInt32(&packageLevelCount, int32(len(packageLevels)))
+}
+
+func hasPackageLevels() bool {
+	return atomic.LoadInt32(&packageLevelCount) > 0
+}
+
+// Returns the level set for the package or its nearest parent package
+func lookupPackageLevel(packageName string) (LogLevel, bool) {
+	if !hasPackageLevels() {
+		return OFF, false
+	}
+
+	packageLevelMutex.RLock()
+	defer packageLevelMutex.RUnlock()
+
+	for {
+		if v := packageLevels[packageName]; v != nil {
+			return v.level, true
+		}
+
+		index := strings.LastIndex(packageName, "/")
+		if index < 0 {
+			return OFF, false
+		}
+
+		packageName = packageName[:index]
+	}
+}
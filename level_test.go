@@ -0,0 +1,203 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPackageLevel(t *testing.T) {
+	var buf bytes.Buffer
+
+	writer := NewLoggerWriter(&buf, ALL)
+	writer.closeFilter = true
+	writer.SetSkipCallerDepth(4)
+	writer.SetFormatter(NewTextFormatterWithFormat("%{Message}"))
+
+	derived := writer.With("key", "value")
+
+	writer.Info("info 1")
+
+	SetPackageLevel("github.com/ronzxy", ERROR)
+	writer.Info("info 2")
+	ResetPackageLevel("github.com/ronzxy")
+	writer.Info("info 3")
+
+	SetPackageLevelWithTTL("github.com/ronzxy/go-logger", WARN, 50*time.Millisecond)
+	writer.Info("info 4")
+
+	for i := 0; i < 100 && len(GetPackageLevels()) > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	writer.Info("info 5")
+
+	// the package level overrides the allow level of the writer, not the deny level
+	SetPackageLevel("github.com/ronzxy", TRACE)
+	writer.SetLevels(WARN, ERROR)
+	derived.Info("info 6")
+	derived.Error("error 7")
+	ResetPackageLevel("github.com/ronzxy")
+	derived.Info("info 8")
+	writer.SetLevels(ALL, OFF)
+
+	if result := strings.TrimSpace(buf.String()); result != "info 1\ninfo 3\ninfo 5\ninfo 6" {
+		t.Errorf("unexpected output %q", result)
+	}
+
+	// a temporary level restores the permanent one
+	SetPackageLevel("github.com/ronzxy", ERROR)
+	SetPackageLevelWithTTL("github.com/ronzxy", TRACE, time.Minute)
+	SetPackageLevelWithTTL("github.com/ronzxy", DEBUG, time.Millisecond)
+
+	for i := 0; i < 100; i++ {
+		if level, _ := lookupPackageLevel("github.com/ronzxy/go-logger"); level == ERROR {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if levels := GetPackageLevels(); len(levels) != 1 || levels[0].Level != "ERROR" || !levels[0].Expires.IsZero() {
+		t.Errorf("unexpected package levels %+v", levels)
+	}
+	ResetPackageLevel("github.com/ronzxy")
+}
+
+// A package raised to TRACE is written to the loggers of higher allow levels,
+// the deny levels still apply
+func TestPackageLevelOverridesWriterLevels(t *testing.T) {
+	_, restore := isolateState(t)
+	defer restore()
+	defer ResetPackageLevel("github.com/ronzxy/go-logger")
+
+	c, err := NewConfigBuilder().
+		MemoryLogger("info", 10).
+		Level("INFO", "").
+		Format("text", "%{Level} %{Message}").
+		MemoryLogger("below-error", 10).
+		Level("INFO", "ERROR").
+		Format("text", "%{Level} %{Message}").
+		DefaultFilter("info", "below-error").
+		PackageFilter("github.com/ronzxy/go-logger", "info", "below-error").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := InitWithConfig(c); err != nil {
+		t.Fatal(err)
+	}
+
+	Trace("trace 1")
+
+	SetPackageLevel("github.com/ronzxy/go-logger", TRACE)
+	Trace("trace 2")
+	Debugf("debug %d", 3)
+	Error("error 4")
+
+	// another package keeps the allow level of the loggers
+	SetPackageLevel("github.com/ronzxy/go-logger", INFO)
+	SetPackageLevel("github.com/ronzxy/other", TRACE)
+	Debug("debug 5")
+	ResetPackageLevel("github.com/ronzxy/other")
+
+	messages := func(name string) string {
+		var result []string
+		for _, entry := range GetWriter(name).(*MemoryLogger).Snapshot() {
+			result = append(result, strings.TrimSpace(entry.Message))
+		}
+
+		return strings.Join(result, ",")
+	}
+
+	if result := messages("info"); result != "TRACE trace 2,DEBUG debug 3,ERROR error 4" {
+		t.Errorf("unexpected info messages %q", result)
+	}
+
+	if result := messages("below-error"); result != "TRACE trace 2,DEBUG debug 3" {
+		t.Errorf("unexpected below-error messages %q", result)
+	}
+}
+
+func TestAdminHandler(t *testing.T) {
+	_, restore := isolateState(t)
+	defer restore()
+
+	c, err := NewConfigBuilder().
+		MemoryLogger("memory", 10).
+		Level("INFO", "").
+		DefaultFilter("memory").
+		PackageFilter("github.com/ronzxy/go-logger/example", "memory").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := InitWithConfig(c); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := NewAdminHandler()
+
+	request := func(method string, values url.Values) (int, adminStatus) {
+		r := httptest.NewRequest(method, "/?"+values.Encode(), nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		var status adminStatus
+		if w.Code == http.StatusOK {
+			if err := json.NewDecoder(w.Body).Decode(&status); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return w.Code, status
+	}
+
+	code, status := request(http.MethodGet, nil)
+	if code != http.StatusOK || len(status.Loggers) != 1 || status.Loggers[0] != (LoggerLevel{"memory", "MEMORY", "INFO", "OFF"}) {
+		t.Errorf("unexpected status %d %+v", code, status)
+	}
+
+	code, status = request(http.MethodPost, url.Values{"logger": {"memory"}, "allow": {"debug"}})
+	if code != http.StatusOK || status.Loggers[0].Allow != "DEBUG" {
+		t.Errorf("unexpected status %d %+v", code, status)
+	}
+
+	code, status = request(http.MethodPost, url.Values{"package": {"github.com/ronzxy/app"}, "level": {"TRACE"}, "ttl": {"1m"}})
+	if code != http.StatusOK || len(status.Packages) != 1 || status.Packages[0].Level != "TRACE" || status.Packages[0].Expires.IsZero() {
+		t.Errorf("unexpected status %d %+v", code, status)
+	}
+
+	code, status = request(http.MethodDelete, url.Values{"package": {"github.com/ronzxy/app"}})
+	if code != http.StatusOK || len(status.Packages) != 0 {
+		t.Errorf("unexpected status %d %+v", code, status)
+	}
+
+	for _, values := range []url.Values{
+		{"logger": {"missing"}, "allow": {"DEBUG"}},
+		{"logger": {"memory"}, "allow": {"VERBOSE"}},
+		{"package": {"github.com/ronzxy/app"}, "level": {"TRACE"}, "ttl": {"soon"}},
+		{},
+	} {
+		if code, _ := request(http.MethodPost, values); code != http.StatusBadRequest {
+			t.Errorf("unexpected code %d for %v", code, values)
+		}
+	}
+}
@@ -13,134 +13,285 @@
 package logger
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/robfig/cron"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 var (
-	config      *Config
-	job         = cron.New()
-	propertyMap = map[string]string{}
-	writerMap   = map[string]Writer{}
-	rolling     = false
-	initialized = false
+	// serialize the initialization, reloading, shutdown and the switches of rolling and watching,
+	// the variables below are guarded by it
+	stateMutex   sync.Mutex
+	configPath   string
+	configFormat string
+	// properties overridden by InitWithOverrides
+	propertyOverrides map[string]string
+	job               = cron.New()
+	rolling           = false
+	rollingStop       chan struct{}
+
+	current = newStateValue()
 )
 
+// The config and writers read by the logging goroutines,
+// replaced as a whole under stateMutex instead of modified
+type state struct {
+	config      *Config
+	writers     map[string]Writer
+	properties  map[string]string
+	initialized bool
+}
+
+// Returns the value of the initial state, initialized before the variables of
+// the package referencing it rather than by func init
+func newStateValue() *atomic.Value {
+	value := &atomic.Value{}
+	value.Store(&state{
+		writers:    map[string]Writer{},
+		properties: map[string]string{},
+	})
+
+	return value
+}
+
+func loadState() *state {
+	return current.Load().(*state)
+}
+
+// Replace the state by a copy changed by update, stateMutex must be held
+func updateState(update func(s *state)) {
+	s := *loadState()
+	update(&s)
+	current.Store(&s)
+}
+
+// Initialize from the config file, the format is selected by the file extension
 func Init(configFile string) error {
-	var (
-		err error
-	)
+	return initFile(configFile, ConfigFormatByExt(configFile), nil)
+}
+
+// Initialize from the config file with format xml, yaml, json or toml
+func InitWithFormat(configFile, format string) error {
+	return initFile(configFile, format, nil)
+}
+
+// Initialize from the config file, the properties are overridden by overrides,
+// such as the values from command line. The overrides are kept by Reload
+func InitWithOverrides(configFile string, overrides map[string]string) error {
+	return initFile(configFile, ConfigFormatByExt(configFile), overrides)
+}
 
-	config, err = NewConfig(configFile)
+func initFile(configFile, format string, overrides map[string]string) error {
+	c, err := NewConfigWithFormat(configFile, format)
 	if err != nil {
 		return err
 	}
 
-	initProperties()
+	c.SetProperties(overrides)
 
-	job.Start()
+	err = validateConfig(c)
+	if err != nil {
+		return err
+	}
 
-	if config.Loggers != nil {
-		// Initialize the Writer of the package filter reference
-		if config.PackageFilters != nil {
-			for _, filter := range config.PackageFilters {
-				if len(filter.Loggers) <= 0 {
-					break
-				}
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
 
-				for _, loggerName := range filter.Loggers {
-					if writerMap[loggerName] != nil {
-						continue
-					}
+	configPath = configFile
+	configFormat = format
+	propertyOverrides = overrides
 
-					logger := initLogger(loggerName)
-					if logger != nil {
-						writerMap[loggerName] = logger
-					}
-				}
-			}
-		}
+	return initConfig(c)
+}
 
-		// Initialize the Writer of the default filter reference
-		if len(config.DefaultFilter.Loggers) > 0 {
-			for _, loggerName := range config.DefaultFilter.Loggers {
-				if writerMap[loggerName] != nil {
-					continue
-				}
+// Initialize with the config built in code, such as by ConfigBuilder.
+// The config is validated as the config file
+func InitWithConfig(c *Config) error {
+	if c == nil {
+		return errors.New("config is nil")
+	}
 
-				logger := initLogger(loggerName)
-				if logger != nil {
-					writerMap[loggerName] = logger
-				}
+	err := validateConfig(c)
+	if err != nil {
+		return err
+	}
+
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+
+	configPath = ""
+	configFormat = ""
+	propertyOverrides = nil
+
+	return initConfig(c)
+}
+
+// Apply c, stateMutex must be held
+func initConfig(c *Config) error {
+	oldState := loadState()
+
+	// the properties are used by creating the writers
+	updateState(func(s *state) {
+		s.config = c
+		s.properties = initProperties(c)
+	})
+
+	job.Start()
+
+	if c.Loggers != nil {
+		var (
+			oldWriters = oldState.writers
+			oldJob     = job
+			newJob     = cron.New()
+		)
+
+		// the writers whose definition is changed are recreated as by Reload
+		writers := initWriters(c, reusableWriters(oldState.config, c, oldWriters, newJob), newJob)
+
+		updateState(func(s *state) {
+			s.writers = writers
+			s.initialized = true
+		})
+		job = newJob
+		oldJob.Stop()
+
+		// close writers not referenced by c
+		for name, writer := range oldWriters {
+			if writers[name] == writer {
+				continue
+			}
+
+			err := writer.Close()
+			if err != nil {
+				Errorf("close logger %s error: %s", name, err.Error())
 			}
 		}
 
-		initialized = true
 		// rolling log file
-		StartRolling()
+		startRolling()
+
+		if c.WatchInterval > 0 && configPath != "" {
+			startWatchConfig(time.Duration(c.WatchInterval) * time.Second)
+		}
 	}
 
 	return nil
 }
 
+// Initialize the Writers referenced by the filters of c,
+// writers in reuse with the same name are kept instead of created
+func initWriters(c *Config, reuse map[string]Writer, rollingJob *cron.Cron) map[string]Writer {
+	var (
+		writers = map[string]Writer{}
+		names   []string
+	)
+
+	// The Writer of the package filter reference
+	for _, filter := range c.PackageFilters {
+		names = append(names, filter.Loggers...)
+	}
+
+	// The Writer of the default filter reference
+	names = append(names, c.DefaultFilter.Loggers...)
+
+	var (
+		visiting = map[string]bool{}
+		create   func(name string)
+	)
+
+	create = func(name string) {
+		if writers[name] != nil || visiting[name] {
+			return
+		}
+
+		if reuse[name] != nil {
+			writers[name] = reuse[name]
+			return
+		}
+
+		// The Writer referenced by the logger are created before it
+		if v := findLogger(c, name); v != nil {
+			visiting[name] = true
+			for _, reference := range v.references() {
+				create(reference)
+			}
+			visiting[name] = false
+		}
+
+		logger := initLogger(c, name, rollingJob, writers)
+		if logger != nil {
+			writers[name] = logger
+		}
+	}
+
+	for _, loggerName := range names {
+		create(loggerName)
+	}
+
+	return writers
+}
+
+// Returns the Writer of the logger name, such as a *MemoryLogger to query
+func GetWriter(name string) Writer {
+	s := loadState()
+	if !s.initialized {
+		return nil
+	}
+
+	return s.writers[name]
+}
+
+// Returns the Writers of the package filters matching the package, the default filter is not included
 func GetByPackage(packageName string) []Writer {
-	if !Initialized() {
+	s := loadState()
+	if !s.initialized {
 		return nil
 	}
 
-	if config.PackageFilters == nil {
+	if s.config.PackageFilters == nil {
 		return nil
 	}
 
 	var writers []Writer
 
-	for _, filter := range config.PackageFilters {
-		//packageName := GetPackageName(frame.Function)
-		if packageName == filter.Name {
-			if len(filter.Loggers) == 0 {
-				// No Logger define
-				return nil
-			}
-
-			for _, name := range filter.Loggers {
-				writer := writerMap[name]
-				if writer == nil {
-					continue
-				}
-				writers = append(writers, writer)
-			}
+	for _, name := range getPackageRoute(s.config, packageName).filterLoggers {
+		writer := s.writers[name]
+		if writer == nil {
+			continue
 		}
+		writers = append(writers, writer)
 	}
 
 	return writers
 }
 
-func initProperties() {
-	if len(config.Properties) > 0 {
-		for _, v := range config.Properties {
-			propertyMap[v.Name] = v.Value
-		}
-	}
+// Resolve the properties of c, the cycles are reported by Validate
+func initProperties(c *Config) map[string]string {
+	properties, _ := ResolveProperties(c.Properties)
+
+	return properties
 }
 
-func initLogger(name string) Writer {
+func initLogger(c *Config, name string, rollingJob *cron.Cron, writers map[string]Writer) Writer {
 	var (
 		err       error
 		formatter Formatter
 	)
 
-	for _, v := range config.Loggers {
+	for _, v := range c.Loggers {
 		if name == v.Name {
 			// 初始化 Formatter
 			switch strings.ToLower(v.Format.Type) {
-			case "text":
-				formatter = NewTextFormatterWithFormat(v.Format.Value)
 			case "json":
-				formatter = NewJSONFormatter()
+				formatter = NewJSONFormatterWithConfig(v)
 			default:
-				formatter = NewTextFormatter()
+				formatter = NewTextFormatterWithConfig(v)
 			}
 
 			switch v.Target {
@@ -153,8 +304,7 @@ func initLogger(name string) Writer {
 
 					consoleLogger = NewConsoleLogger(ConvertString2Level(v.Level.Allow))
 					consoleLogger.SetDenyLevel(ConvertString2Level(v.Level.Deny))
-					consoleLogger.SetFormatter(formatter)
-					consoleLogger.name = v.Name
+					initLoggerWriter(consoleLogger.LoggerWriter, v, formatter)
 
 					return consoleLogger
 				}
@@ -167,23 +317,109 @@ func initLogger(name string) Writer {
 
 					fileLogger, err = NewFileLoggerWithConfig(v)
 					if err == nil {
-						if v.Rolling.TimeBased == "" {
-							v.Rolling.TimeBased = "@daily"
-						}
+						addRollingJob(rollingJob, fileLogger)
 
-						err = job.AddFunc(v.Rolling.TimeBased, fileLogger.RollingFile)
-						if err != nil {
-							Errorf("create cron error %s", err.Error())
-						}
-
-						fileLogger.SetFormatter(formatter)
-						fileLogger.name = v.Name
+						initLoggerWriter(fileLogger.LoggerWriter, v, formatter)
 
 						return fileLogger
 					} else {
 						DefaultConsoleLogger().Error(err.Error())
 					}
 				}
+			case "SYSLOG":
+				{
+					// Syslog
+					var (
+						syslogLogger *SyslogLogger
+					)
+
+					syslogLogger, err = NewSyslogLoggerWithConfig(v)
+					if err == nil {
+						initLoggerWriter(syslogLogger.LoggerWriter, v, formatter)
+
+						return syslogLogger
+					} else {
+						DefaultConsoleLogger().Error(err.Error())
+					}
+				}
+			case "NETWORK":
+				{
+					// Network Log
+					var (
+						networkLogger *NetworkLogger
+					)
+
+					networkLogger, err = NewNetworkLoggerWithConfig(v)
+					if err == nil {
+						initLoggerWriter(networkLogger.LoggerWriter, v, formatter)
+
+						return networkLogger
+					} else {
+						DefaultConsoleLogger().Error(err.Error())
+					}
+				}
+			case "HTTP":
+				{
+					// HTTP Log
+					var (
+						httpLogger *HTTPLogger
+					)
+
+					httpLogger = NewHTTPLoggerWithConfig(v)
+					initLoggerWriter(httpLogger.LoggerWriter, v, formatter)
+
+					return httpLogger
+				}
+			case "DATABASE":
+				{
+					// Database Log
+					var (
+						databaseLogger *DatabaseLogger
+					)
+
+					databaseLogger, err = NewDatabaseLoggerWithConfig(v)
+					if err == nil {
+						addPurgeJob(rollingJob, databaseLogger)
+
+						initLoggerWriter(databaseLogger.LoggerWriter, v, formatter)
+
+						return databaseLogger
+					} else {
+						DefaultConsoleLogger().Error(err.Error())
+					}
+				}
+			case "MULTI":
+				{
+					// Fan out to other loggers
+					var (
+						multiLogger *MultiLogger
+					)
+
+					multiLogger, err = NewMultiLoggerWithConfig(v, writers)
+					if err == nil {
+						initLoggerWriter(multiLogger.LoggerWriter, v, formatter)
+
+						return multiLogger
+					} else {
+						DefaultConsoleLogger().Error(err.Error())
+					}
+				}
+			case "MEMORY":
+				{
+					// Ring buffer of the last entries
+					var (
+						memoryLogger *MemoryLogger
+					)
+
+					memoryLogger, err = NewMemoryLoggerWithConfig(v, writers)
+					if err == nil {
+						initLoggerWriter(memoryLogger.LoggerWriter, v, formatter)
+
+						return memoryLogger
+					} else {
+						DefaultConsoleLogger().Error(err.Error())
+					}
+				}
 			default:
 				DefaultConsoleLogger().Warnf("unsupported log target %s", v.Target)
 			}
@@ -193,48 +429,165 @@ func initLogger(name string) Writer {
 	return nil
 }
 
-func rollingFileSize() {
-	if config.RollingInterval <= 0 {
-		config.RollingInterval = 60
+// Apply the common options of v to the LoggerWriter of a target
+func initLoggerWriter(writer *LoggerWriter, v Logger, formatter Formatter) {
+	writer.SetFormatter(formatter)
+	writer.name = v.Name
+
+	if v.Sampling.Type != "" {
+		writer.SetSampler(NewSamplerWithConfig(v.Sampling))
+	}
+
+	if v.Dedup.Window > 0 {
+		writer.SetDeduplicator(NewDeduplicatorWithConfig(v.Dedup))
+	}
+
+	if v.Async {
+		writer.SetAsync(v.BufferSize, ConvertString2Overflow(v.Overflow))
+	}
+}
+
+// Register the time based rolling of fileLogger to rollingJob
+func addRollingJob(rollingJob *cron.Cron, fileLogger *FileLogger) {
+	timeBased := fileLogger.config.Rolling.TimeBased
+	if timeBased == "" {
+		timeBased = "@daily"
+	}
+
+	err := rollingJob.AddFunc(timeBased, fileLogger.RollingFile)
+	if err != nil {
+		Errorf("create cron error %s", err.Error())
+	}
+}
+
+// Register the retention purge of databaseLogger to rollingJob
+func addPurgeJob(rollingJob *cron.Cron, databaseLogger *DatabaseLogger) {
+	if databaseLogger.config.Retention <= 0 {
+		return
 	}
 
+	timeBased := databaseLogger.config.Rolling.TimeBased
+	if timeBased == "" {
+		timeBased = "@daily"
+	}
+
+	err := rollingJob.AddFunc(timeBased, databaseLogger.Purge)
+	if err != nil {
+		Errorf("create cron error %s", err.Error())
+	}
+}
+
+func rollingFileSize(stop chan struct{}) {
 	for {
+		interval := 60 * time.Second
+		if c := loadState().config; c != nil && c.RollingInterval > 0 {
+			interval = time.Duration(c.RollingInterval) * time.Second
+		}
+
 		select {
-		case <-time.After(time.Duration(config.RollingInterval) * time.Second):
+		case <-stop:
+			// rolling disabled, exit loop
+			return
+		case <-time.After(interval):
 			// rolling file
-			if writerMap != nil && rolling {
-				for _, v := range writerMap {
-					v.CheckRollingSize()
-				}
+			for _, v := range loadState().writers {
+				v.CheckRollingSize()
 			}
 		}
-
-		// if disable rolling break loop
-		if !rolling {
-			break
-		}
 	}
 }
 
 func StartRolling() {
-	rolling = true
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+
+	startRolling()
+}
+
+func startRolling() {
 	job.Start()
 
-	go rollingFileSize()
+	if rolling {
+		return
+	}
+
+	rolling = true
+	rollingStop = make(chan struct{})
+
+	go rollingFileSize(rollingStop)
 }
 
 func StopRolling() {
-	rolling = false
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+
+	stopRolling()
+}
+
+func stopRolling() {
 	job.Stop()
+
+	if rolling {
+		rolling = false
+		close(rollingStop)
+	}
+}
+
+// Stop the rolling and config reloading, then close all writers.
+// The in-progress rolling is finished before its writer is closed,
+// returns ctx.Err() if ctx is done before all writers are closed
+func Shutdown(ctx context.Context) error {
+	stateMutex.Lock()
+
+	stopWatchConfig()
+	stopReloadOnSignal()
+	stopRolling()
+
+	writers := loadState().writers
+	updateState(func(s *state) {
+		s.writers = map[string]Writer{}
+		s.initialized = false
+	})
+
+	stateMutex.Unlock()
+
+	done := make(chan error, 1)
+	go func() {
+		var errs MultiError
+		for name, writer := range writers {
+			err := writer.Close()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("close logger %s error: %s", name, err.Error()))
+			}
+		}
+
+		done <- errs.ErrorOrNil()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Wait until the queued entries of all writers are written
+func Flush() {
+	if Initialized() {
+		for _, value := range loadState().writers {
+			value.Flush()
+		}
+	}
 }
 
 func Initialized() bool {
-	return initialized
+	return loadState().initialized
 }
 
 func Tracef(format string, args ...interface{}) {
 	if Initialized() {
-		for _, value := range writerMap {
+		for _, value := range loadState().writers {
 			value.Tracef(format, args...)
 		}
 	} else {
@@ -244,7 +597,7 @@ func Tracef(format string, args ...interface{}) {
 
 func Debugf(format string, args ...interface{}) {
 	if Initialized() {
-		for _, value := range writerMap {
+		for _, value := range loadState().writers {
 			value.Debugf(format, args...)
 		}
 	} else {
@@ -254,7 +607,7 @@ func Debugf(format string, args ...interface{}) {
 
 func Infof(format string, args ...interface{}) {
 	if Initialized() {
-		for _, value := range writerMap {
+		for _, value := range loadState().writers {
 			value.Infof(format, args...)
 		}
 	} else {
@@ -264,7 +617,7 @@ func Infof(format string, args ...interface{}) {
 
 func Warnf(format string, args ...interface{}) {
 	if Initialized() {
-		for _, value := range writerMap {
+		for _, value := range loadState().writers {
 			value.Warnf(format, args...)
 		}
 	} else {
@@ -274,7 +627,7 @@ func Warnf(format string, args ...interface{}) {
 
 func Errorf(format string, args ...interface{}) {
 	if Initialized() {
-		for _, value := range writerMap {
+		for _, value := range loadState().writers {
 			value.Errorf(format, args...)
 		}
 	} else {
@@ -284,18 +637,19 @@ func Errorf(format string, args ...interface{}) {
 
 func Fatalf(format string, args ...interface{}) {
 	if Initialized() {
-		for _, value := range writerMap {
+		for _, value := range loadState().writers {
 			value.FatalfWithExit(false, format, args...)
 		}
 	} else {
 		DefaultConsoleLogger().FatalfWithExit(false, format, args...)
 	}
+	Flush()
 	os.Exit(-1)
 }
 
 func Trace(args ...interface{}) {
 	if Initialized() {
-		for _, value := range writerMap {
+		for _, value := range loadState().writers {
 			value.Trace(args...)
 		}
 	} else {
@@ -305,7 +659,7 @@ func Trace(args ...interface{}) {
 
 func Debug(args ...interface{}) {
 	if Initialized() {
-		for _, value := range writerMap {
+		for _, value := range loadState().writers {
 			value.Debug(args...)
 		}
 	} else {
@@ -315,7 +669,7 @@ func Debug(args ...interface{}) {
 
 func Info(args ...interface{}) {
 	if Initialized() {
-		for _, value := range writerMap {
+		for _, value := range loadState().writers {
 			value.Info(args...)
 		}
 	} else {
@@ -325,7 +679,7 @@ func Info(args ...interface{}) {
 
 func Warn(args ...interface{}) {
 	if Initialized() {
-		for _, value := range writerMap {
+		for _, value := range loadState().writers {
 			value.Warn(args...)
 		}
 	} else {
@@ -335,7 +689,7 @@ func Warn(args ...interface{}) {
 
 func Error(args ...interface{}) {
 	if Initialized() {
-		for _, value := range writerMap {
+		for _, value := range loadState().writers {
 			value.Error(args...)
 		}
 	} else {
@@ -345,11 +699,136 @@ func Error(args ...interface{}) {
 
 func Fatal(args ...interface{}) {
 	if Initialized() {
-		for _, value := range writerMap {
+		for _, value := range loadState().writers {
 			value.FatalWithExit(false, args...)
 		}
 	} else {
 		DefaultConsoleLogger().FatalWithExit(false, args...)
 	}
+	Flush()
+	os.Exit(-1)
+}
+
+func TracefCtx(ctx context.Context, format string, args ...interface{}) {
+	if Initialized() {
+		for _, value := range loadState().writers {
+			value.TracefCtx(ctx, format, args...)
+		}
+	} else {
+		DefaultConsoleLogger().TracefCtx(ctx, format, args...)
+	}
+}
+
+func DebugfCtx(ctx context.Context, format string, args ...interface{}) {
+	if Initialized() {
+		for _, value := range loadState().writers {
+			value.DebugfCtx(ctx, format, args...)
+		}
+	} else {
+		DefaultConsoleLogger().DebugfCtx(ctx, format, args...)
+	}
+}
+
+func InfofCtx(ctx context.Context, format string, args ...interface{}) {
+	if Initialized() {
+		for _, value := range loadState().writers {
+			value.InfofCtx(ctx, format, args...)
+		}
+	} else {
+		DefaultConsoleLogger().InfofCtx(ctx, format, args...)
+	}
+}
+
+func WarnfCtx(ctx context.Context, format string, args ...interface{}) {
+	if Initialized() {
+		for _, value := range loadState().writers {
+			value.WarnfCtx(ctx, format, args...)
+		}
+	} else {
+		DefaultConsoleLogger().WarnfCtx(ctx, format, args...)
+	}
+}
+
+func ErrorfCtx(ctx context.Context, format string, args ...interface{}) {
+	if Initialized() {
+		for _, value := range loadState().writers {
+			value.ErrorfCtx(ctx, format, args...)
+		}
+	} else {
+		DefaultConsoleLogger().ErrorfCtx(ctx, format, args...)
+	}
+}
+
+func FatalfCtx(ctx context.Context, format string, args ...interface{}) {
+	if Initialized() {
+		for _, value := range loadState().writers {
+			value.FatalfCtxWithExit(ctx, false, format, args...)
+		}
+	} else {
+		DefaultConsoleLogger().FatalfCtxWithExit(ctx, false, format, args...)
+	}
+	Flush()
+	os.Exit(-1)
+}
+
+func TraceCtx(ctx context.Context, args ...interface{}) {
+	if Initialized() {
+		for _, value := range loadState().writers {
+			value.TraceCtx(ctx, args...)
+		}
+	} else {
+		DefaultConsoleLogger().TraceCtx(ctx, args...)
+	}
+}
+
+func DebugCtx(ctx context.Context, args ...interface{}) {
+	if Initialized() {
+		for _, value := range loadState().writers {
+			value.DebugCtx(ctx, args...)
+		}
+	} else {
+		DefaultConsoleLogger().DebugCtx(ctx, args...)
+	}
+}
+
+func InfoCtx(ctx context.Context, args ...interface{}) {
+	if Initialized() {
+		for _, value := range loadState().writers {
+			value.InfoCtx(ctx, args...)
+		}
+	} else {
+		DefaultConsoleLogger().InfoCtx(ctx, args...)
+	}
+}
+
+func WarnCtx(ctx context.Context, args ...interface{}) {
+	if Initialized() {
+		for _, value := range loadState().writers {
+			value.WarnCtx(ctx, args...)
+		}
+	} else {
+		DefaultConsoleLogger().WarnCtx(ctx, args...)
+	}
+}
+
+func ErrorCtx(ctx context.Context, args ...interface{}) {
+	if Initialized() {
+		for _, value := range loadState().writers {
+			value.ErrorCtx(ctx, args...)
+		}
+	} else {
+		DefaultConsoleLogger().ErrorCtx(ctx, args...)
+	}
+}
+
+func FatalCtx(ctx context.Context, args ...interface{}) {
+	if Initialized() {
+		for _, value := range loadState().writers {
+			value.FatalCtxWithExit(ctx, false, args...)
+		}
+	} else {
+		DefaultConsoleLogger().FatalCtxWithExit(ctx, false, args...)
+	}
+	Flush()
 	os.Exit(-1)
 }
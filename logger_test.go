@@ -14,13 +14,71 @@
 package logger
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/robfig/cron"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 var (
 	err = Init("example/logger.xml")
 )
 
+// Detach the global state for a test which initializes its own config, the returned func
+// closes the writers created by the test, restores the state and removes the temporary directory
+func isolateState(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "logger-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+
+	var (
+		saved          = loadState()
+		savedJob       = job
+		savedPath      = configPath
+		savedFormat    = configFormat
+		savedOverrides = propertyOverrides
+	)
+
+	stopWatchConfig()
+	current.Store(&state{writers: map[string]Writer{}, properties: map[string]string{}})
+	job = cron.New()
+
+	return dir, func() {
+		defer os.RemoveAll(dir)
+
+		stateMutex.Lock()
+		defer stateMutex.Unlock()
+
+		stopWatchConfig()
+		job.Stop()
+		for _, writer := range loadState().writers {
+			writer.Close()
+		}
+
+		current.Store(saved)
+		job = savedJob
+		configPath, configFormat, propertyOverrides = savedPath, savedFormat, savedOverrides
+
+		if saved.initialized {
+			startRolling()
+			if saved.config.WatchInterval > 0 && configPath != "" {
+				startWatchConfig(time.Duration(saved.config.WatchInterval) * time.Second)
+			}
+		}
+	}
+}
+
 func TestLogger(t *testing.T) {
 	DefaultConsoleLogger().SetSkipCallerDepth(4)
 	if err != nil {
@@ -37,6 +95,275 @@ func TestLogger(t *testing.T) {
 	t.Log("Test Logger finished.")
 }
 
+func TestWithFields(t *testing.T) {
+	var buf bytes.Buffer
+
+	writer := NewLoggerWriter(&buf, ALL)
+	writer.closeFilter = true
+	writer.SetFormatter(NewTextFormatterWithFormat("%{Level} %{Fields} %{Message}"))
+
+	writer.With("user", "ron", "id", 1024).WithFields(map[string]interface{}{"path": "/a b"}).Info("with fields")
+	if line := strings.TrimSpace(buf.String()); line != `INFO id=1024 path="/a b" user=ron with fields` {
+		t.Errorf("unexpected text line: %s", line)
+	}
+
+	buf.Reset()
+	writer.SetFormatter(NewJSONFormatter())
+	writer.With("user", "ron", "Level", "x").Info("with fields")
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &data); err != nil {
+		t.Fatal(err)
+	}
+
+	if data["user"] != "ron" || data["Level"] != "INFO" || data["fields.Level"] != "x" {
+		t.Errorf("unexpected json line: %s", buf.String())
+	}
+}
+
+// The writers with fields are derived once, and again after Init replaces the writers
+func TestFieldsLoggerWriters(t *testing.T) {
+	_, restore := isolateState(t)
+	defer restore()
+
+	newConfig := func() *Config {
+		c, err := NewConfigBuilder().
+			MemoryLogger("memory", 10).
+			Level("ALL", "").
+			Format("text", "%{Fields} %{Message}").
+			DefaultFilter("memory").
+			PackageFilter("github.com/ronzxy/go-logger", "memory").
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return c
+	}
+
+	if err := InitWithConfig(newConfig()); err != nil {
+		t.Fatal(err)
+	}
+
+	fieldsLogger := With("user", "ron")
+	first := fieldsLogger.writers()
+	if second := fieldsLogger.writers(); len(second) != 1 || second[0] != first[0] {
+		t.Errorf("unexpected writers %v, %v", first, second)
+	}
+
+	if err := InitWithConfig(newConfig()); err != nil {
+		t.Fatal(err)
+	}
+
+	fieldsLogger.Info("after init")
+	if second := fieldsLogger.writers(); second[0] == first[0] {
+		t.Error("unexpected writers of the replaced state")
+	}
+
+	if entries := GetWriter("memory").(*MemoryLogger).Snapshot(); len(entries) != 1 || strings.TrimSpace(entries[0].Message) != "user=ron after init" {
+		t.Errorf("unexpected entries %v", entries)
+	}
+}
+
+func TestContext(t *testing.T) {
+	var buf bytes.Buffer
+
+	writer := NewLoggerWriter(&buf, ALL)
+	writer.closeFilter = true
+	writer.SetSkipCallerDepth(4)
+	writer.SetFormatter(NewTextFormatterWithFormat("%{File} %{Ctx:request_id} %{Ctx:trace_id} %{Message}"))
+
+	ctx := ContextWithTraceID(ContextWithRequestID(context.Background(), "req-1"), "trace-1")
+	writer.InfoCtx(ctx, "with context")
+	if line := strings.TrimSpace(buf.String()); line != "logger_test.go req-1 trace-1 with context" {
+		t.Errorf("unexpected text line: %s", line)
+	}
+}
+
+// Writers not referenced by the config of a second Init are closed
+func TestInitClosesDroppedWriters(t *testing.T) {
+	dir, restore := isolateState(t)
+	defer restore()
+
+	newConfig := func(name string) *Config {
+		c, err := NewConfigBuilder().
+			Property("LOG_PATH", dir).
+			FileLogger(name, "${LOG_PATH}/"+name+".log", "${LOG_PATH}/"+name+"-%{i}.log").
+			Level("ALL", "").
+			DefaultFilter(name).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return c
+	}
+
+	if err := InitWithConfig(newConfig("first")); err != nil {
+		t.Fatal(err)
+	}
+	first := loadState().writers["first"].(*FileLogger)
+
+	if err := InitWithConfig(newConfig("second")); err != nil {
+		t.Fatal(err)
+	}
+
+	if !first.closed || loadState().writers["first"] != nil {
+		t.Error("the dropped writer is not closed")
+	}
+}
+
+// Writers with the same name are kept by a second Init only if their definition is not changed
+func TestInitRecreatesChangedWriters(t *testing.T) {
+	dir, restore := isolateState(t)
+	defer restore()
+
+	newConfig := func(fileName string) *Config {
+		c, err := NewConfigBuilder().
+			Property("LOG_PATH", dir).
+			FileLogger("file", "${LOG_PATH}/"+fileName, "${LOG_PATH}/file-%{i}.log").
+			Level("ALL", "").
+			DefaultFilter("file").
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return c
+	}
+
+	if err := InitWithConfig(newConfig("first.log")); err != nil {
+		t.Fatal(err)
+	}
+	first := loadState().writers["file"].(*FileLogger)
+
+	if err := InitWithConfig(newConfig("first.log")); err != nil {
+		t.Fatal(err)
+	}
+
+	if loadState().writers["file"] != first || first.closed {
+		t.Error("the unchanged writer is not kept")
+	}
+
+	if err := InitWithConfig(newConfig("second.log")); err != nil {
+		t.Fatal(err)
+	}
+
+	second := loadState().writers["file"].(*FileLogger)
+	if second == first || !first.closed || second.writer.Name() != path.Join(dir, "second.log") {
+		t.Errorf("the changed writer is not recreated, %s", second.writer.Name())
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	dir, restore := isolateState(t)
+	defer restore()
+
+	configFile := path.Join(dir, "logger.xml")
+	writeReloadTestConfig(t, configFile, dir, "shutdown")
+
+	if err := Init(configFile); err != nil {
+		t.Fatal(err)
+	}
+	fileLogger := loadState().writers["shutdown"].(*FileLogger)
+	fileLogger.SetAsync(16, OverflowBlock)
+
+	Info("before shutdown")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if Initialized() || rolling || len(loadState().writers) != 0 {
+		t.Error("logger is not shutdown")
+	}
+
+	if err := fileLogger.Close(); err != nil {
+		t.Errorf("close twice error: %s", err.Error())
+	}
+
+	content, err := ioutil.ReadFile(path.Join(dir, "shutdown.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.TrimSpace(string(content)) != "INFO before shutdown" {
+		t.Errorf("unexpected content: %s", content)
+	}
+}
+
+// Log by the package functions and named loggers while initializing and changing levels, run with -race
+func TestConcurrentLogging(t *testing.T) {
+	_, restore := isolateState(t)
+	defer restore()
+
+	newConfig := func() *Config {
+		c, err := NewConfigBuilder().
+			MemoryLogger("memory", 100).
+			Level("ALL", "").
+			Dedup(1000).
+			DefaultFilter("memory").
+			PackageFilter("github.com/ronzxy/go-logger/example", "memory").
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return c
+	}
+
+	if err := InitWithConfig(newConfig()); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		wg   sync.WaitGroup
+		done = make(chan struct{})
+	)
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			named := GetLogger("github.com/ronzxy/go-logger/stress")
+			for j := 0; j < 200; j++ {
+				Infof("package %d %d", i, j)
+				With("goroutine", i).Warn("fields")
+				InfoCtx(ContextWithRequestID(context.Background(), "req"), "context")
+				named.Debugf("named %d %d", i, j)
+			}
+		}(i)
+	}
+
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	for i := 0; ; i++ {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		if err := InitWithConfig(newConfig()); err != nil {
+			t.Fatal(err)
+		}
+
+		SetLevel("memory", LogLevel(i%3), OFF)
+		SetPackageLevelWithTTL("github.com/ronzxy/go-logger", DEBUG, time.Millisecond)
+		GetLevels()
+		Flush()
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
 func BenchmarkLogger(b *testing.B) {
 	DefaultConsoleLogger().SetSkipCallerDepth(4)
 	if err != nil {
@@ -54,3 +381,39 @@ func BenchmarkLogger(b *testing.B) {
 		b.Log("Benchmark Logger finished.")
 	}
 }
+
+// go test -run=^$ -bench=LoggerWriter -benchmem
+func BenchmarkLoggerWriter(b *testing.B) {
+	writer := NewLoggerWriter(ioutil.Discard, ALL)
+	writer.closeFilter = true
+	writer.SetSkipCallerDepth(4)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		writer.Info("Benchmark LoggerWriter info message")
+	}
+}
+
+func BenchmarkLoggerWriterf(b *testing.B) {
+	writer := NewLoggerWriter(ioutil.Discard, ALL)
+	writer.closeFilter = true
+	writer.SetSkipCallerDepth(4)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		writer.Infof("Benchmark LoggerWriter %s message", "info")
+	}
+}
+
+// The entries below the allowed level are rejected without allocations
+func BenchmarkLoggerWriterDisabled(b *testing.B) {
+	writer := NewLoggerWriter(ioutil.Discard, INFO)
+	writer.closeFilter = true
+	writer.SetSkipCallerDepth(4)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		writer.Debug("Benchmark LoggerWriter debug message")
+		writer.Debugf("Benchmark LoggerWriter %s message", "debug")
+	}
+}
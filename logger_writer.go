@@ -13,32 +13,56 @@
 package logger
 
 import (
+	"context"
 	"fmt"
 	"github.com/ronzxy/go-helper"
 	"io"
 	"log"
 	"os"
 	"runtime"
+	"sync/atomic"
+	"time"
 	xormlog "github.com/ronzxy/go-xorm/log"
 )
 
+// The allow and deny levels shared with the derived writers, changed at runtime by SetLevels
+type writerLevels struct {
+	allow int32
+	deny  int32
+}
+
+func (this *writerLevels) get() (LogLevel, LogLevel) {
+	return LogLevel(atomic.LoadInt32(&this.allow)), LogLevel(atomic.LoadInt32(&this.deny))
+}
+
+func (this *writerLevels) set(allow, deny LogLevel) {
+	atomic.StoreInt32(&this.allow, int32(allow))
+	atomic.StoreInt32(&this.deny, int32(deny))
+}
+
 type LoggerWriter struct {
-	allowLevel      LogLevel
-	denyLevel       LogLevel
+	levels          *writerLevels
 	prefix          string // 工作名
 	name            string // 日志名
 	formatter       Formatter
 	skipCallerDepth int
 	closeFilter     bool
 	showSQL         bool
+	fields          map[string]interface{} // 结构化字段
+	derived         bool                   // 由 WithFields 派生，与父日志共享输出
+	async           *AsyncWriter
+	entryWriter     EntryWriter  // 替代 log.Logger 输出
+	unformatted     bool         // 不格式化条目，由 entryWriter 格式化
+	exitFlush       func() error // Fatal 退出前替代 Flush，由批量输出设置
+	sampler         *Sampler
+	dedup           *Deduplicator
 
 	*log.Logger
 }
 
 func NewLoggerWriter(w io.Writer, level LogLevel) *LoggerWriter {
 	this := &LoggerWriter{
-		allowLevel:      level,
-		denyLevel:       OFF,
+		levels:          &writerLevels{allow: int32(level), deny: OFF},
 		prefix:          helper.Path.WorkName(),
 		skipCallerDepth: defaultSkipCallerDepth,
 		Logger:          log.New(w, "", log.LUTC),
@@ -51,102 +75,354 @@ func NewLoggerWriter(w io.Writer, level LogLevel) *LoggerWriter {
 
 func (this *LoggerWriter) NewLogger(w io.Writer) {
 	this.Logger = log.New(w, "", log.LUTC)
+	this.setFormatterOutput()
 }
 
 func (this *LoggerWriter) SetDenyLevel(level LogLevel) {
-	if level > this.denyLevel {
-		this.allowLevel = OFF
+	allowLevel, denyLevel := this.levels.get()
+	if level > denyLevel {
+		this.levels.set(OFF, denyLevel)
 	} else {
-		this.denyLevel = level
+		this.levels.set(allowLevel, level)
 	}
 }
 
+// Change the allow and deny levels at runtime, the derived writers are changed too
+func (this *LoggerWriter) SetLevels(allow, deny LogLevel) {
+	this.levels.set(allow, deny)
+}
+
+// Returns the allow and deny levels
+func (this *LoggerWriter) Levels() (LogLevel, LogLevel) {
+	return this.levels.get()
+}
+
 func (this *LoggerWriter) SetSkipCallerDepth(skipCallerDepth int) {
 	this.skipCallerDepth = skipCallerDepth
 }
 
 func (this *LoggerWriter) SetWriter(w io.Writer) {
 	this.SetOutput(w)
+	this.setFormatterOutput()
 }
 
 func (this *LoggerWriter) SetFormatter(formatter Formatter) {
 	this.formatter = formatter
+	this.setFormatterOutput()
 }
 
-func (this *LoggerWriter) filter(frame *runtime.Frame) bool {
-	if this.closeFilter {
-		return true
+// Tell the formatter the output it feeds, the colors are decided by it in ColorAuto mode
+func (this *LoggerWriter) setFormatterOutput() {
+	if formatter, ok := this.formatter.(*TextFormatter); ok && this.Logger != nil {
+		formatter.setOutput(this.Writer())
 	}
+}
 
-	if config.PackageFilters == nil {
-		return false
+// Limit the entries of every level and call site by sampler, nil means no limit
+func (this *LoggerWriter) SetSampler(sampler *Sampler) {
+	if this.sampler != nil {
+		this.sampler.stop()
+	}
+
+	if sampler != nil {
+		sampler.setOutput(this.appendSummary)
+	}
+
+	this.sampler = sampler
+}
+
+// Collapse the consecutive identical entries by dedup, nil means no deduplication
+func (this *LoggerWriter) SetDeduplicator(dedup *Deduplicator) {
+	if dedup != nil {
+		dedup.setOutput(this.Append)
+	}
+
+	this.dedup = dedup
+}
+
+// Write log entries to w instead of the io.Writer
+func (this *LoggerWriter) SetEntryWriter(w EntryWriter) {
+	this.entryWriter = w
+}
+
+// Write log entries by a background goroutine with a buffer of bufferSize entries
+func (this *LoggerWriter) SetAsync(bufferSize int, overflow OverflowPolicy) {
+	if this.async != nil {
+		this.async.Close()
 	}
 
-	for _, filter := range config.PackageFilters {
-		packageName := GetPackageName(frame.Function)
-		if packageName == filter.Name {
-			if len(filter.Loggers) == 0 {
-				// No Logger define
-				return false
-			}
+	this.async = NewAsyncWriter(bufferSize, overflow, this.output)
+}
+
+// The count of entries dropped by the async buffer
+func (this *LoggerWriter) Dropped() uint64 {
+	if this.async == nil {
+		return 0
+	}
+
+	return this.async.Dropped()
+}
+
+// Wait until the queued entries are written
+func (this *LoggerWriter) Flush() error {
+	if this.async == nil {
+		return nil
+	}
+
+	return this.async.Flush()
+}
+
+// Flush before os.Exit by Fatal, the batched targets write their pending batch too
+func (this *LoggerWriter) flushBeforeExit() error {
+	if this.exitFlush != nil {
+		return this.exitFlush()
+	}
 
-			for _, name := range filter.Loggers {
-				if name == this.name {
-					return true
-				}
-			}
+	return this.Flush()
+}
+
+// Write the queued entries and stop the async goroutine,
+// writers derived by WithFields only flush the shared output
+func (this *LoggerWriter) Close() error {
+	if this.sampler != nil && !this.derived {
+		this.sampler.stop()
+		this.summarize(time.Now(), true)
+	}
+
+	if this.dedup != nil && !this.derived {
+		if repeated := this.dedup.End(); repeated != nil {
+			this.Append(repeated)
 		}
 	}
 
-	if len(config.DefaultFilter.Loggers) == 0 {
-		// No Logger define
+	if this.async == nil || this.derived {
+		return this.Flush()
+	}
+
+	return this.async.Close()
+}
+
+// Returns a derived writer that shares the output of this writer
+// and adds the fields to every log entry
+func (this *LoggerWriter) WithFields(fields map[string]interface{}) Writer {
+	return this.withFields(fields)
+}
+
+// Returns a derived writer with the alternating key/value pairs as fields
+func (this *LoggerWriter) With(keyValues ...interface{}) Writer {
+	return this.withFields(KeyValues2Fields(keyValues...))
+}
+
+func (this *LoggerWriter) withFields(fields map[string]interface{}) *LoggerWriter {
+	writer := *this
+	writer.fields = MergeFields(this.fields, fields)
+	writer.derived = true
+
+	return &writer
+}
+
+// Do nothing with implement interface Writer
+func (this *LoggerWriter) CheckRollingSize() {}
+
+func (this *LoggerWriter) filter(frame *runtime.Frame, level LogLevel) bool {
+	packageName := GetPackageName(frame.Function)
+
+	// The level set by SetPackageLevel overrides the allow level of the logger
+	// and the level of the package filters
+	packageLevel, override := lookupPackageLevel(packageName)
+	if override && level < packageLevel {
 		return false
 	}
 
-	// TODO: if define by package name whether the default filer can be used?
-	for _, name := range config.DefaultFilter.Loggers {
-		if name == this.name {
-			return true
-		}
+	if this.closeFilter {
+		return true
 	}
 
-	return false
+	c := loadState().config
+	if c == nil || c.PackageFilters == nil {
+		return false
+	}
+
+	route := getPackageRoute(c, packageName)
+	if override {
+		return route.loggers[this.name]
+	}
+
+	return route.allows(this.name, level)
 }
 
 func (this *LoggerWriter) Write(level LogLevel, args ...interface{}) error {
+	return this.write(nil, level, args...)
+}
+
+// Write with the values extracted from ctx by the registered ContextExtractor
+func (this *LoggerWriter) WriteCtx(ctx context.Context, level LogLevel, args ...interface{}) error {
+	return this.write(ctx, level, args...)
+}
+
+func (this *LoggerWriter) write(ctx context.Context, level LogLevel, args ...interface{}) error {
 	if len(args) <= 0 {
 		return fmt.Errorf("empty args")
 	}
 
-	// Reject logs that are less than the allowed level
-	if level < this.allowLevel {
+	// skip one more frame for Write or WriteCtx
+	frame := this.accept(level, 2)
+	if frame == nil {
 		return nil
 	}
 
+	// copied, so the args of the rejected entries are not allocated by the callers
+	return this.emitFrame(ctx, level, frame, append([]interface{}(nil), args...))
+}
+
+// Write the entry of the *f methods, the args are formatted only if the entry is accepted
+func (this *LoggerWriter) writef(ctx context.Context, level LogLevel, format string, args []interface{}) error {
+	frame := this.accept(level, 1)
+	if frame == nil {
+		return nil
+	}
+
+	return this.emitFrame(ctx, level, frame, []interface{}{fmt.Sprintf(format, args...)})
+}
+
+// Returns the caller frame if an entry of level is allowed by the levels and package filters, otherwise nil.
+// depth is the count of frames between the caller of accept and the method called by the user
+func (this *LoggerWriter) accept(level LogLevel, depth int) *runtime.Frame {
+	allowLevel, denyLevel := this.levels.get()
+
 	// Reject logs greater than or equal to the rejection level
-	if level >= this.denyLevel {
+	if level >= denyLevel {
 		return nil
 	}
 
-	frame := GetCaller(this.skipCallerDepth)
+	// Reject logs that are less than the allowed level, unless a package level set at runtime
+	// may override it for the package of the caller
+	if level < allowLevel && !hasPackageLevels() {
+		return nil
+	}
+
+	frame := GetCaller(this.skipCallerDepth + depth)
+
+	if level < allowLevel {
+		if _, override := lookupPackageLevel(GetPackageName(frame.Function)); !override {
+			return nil
+		}
+	}
 
-	if !this.filter(frame) {
+	if !this.filter(frame, level) {
 		return nil
 	}
 
-	var (
-		data = map[string]interface{}{}
-	)
+	return frame
+}
+
+func (this *LoggerWriter) emitFrame(ctx context.Context, level LogLevel, frame *runtime.Frame, args []interface{}) error {
+	return this.Emit(&Entry{
+		Time:        time.Now(),
+		Level:       level,
+		PackageName: GetPackageName(frame.Function),
+		Function:    frame.Function,
+		File:        GetFileName(frame),
+		Line:        frame.Line,
+		GoroutineID: captureGoroutineID(),
+		Ctx:         ExtractContext(ctx),
+		Args:        args,
+	})
+}
+
+// Write the entry located by the caller, such as NamedLogger, with the prefix and fields of this writer.
+// The sampler and deduplicator are applied, but not the levels and package filters
+func (this *LoggerWriter) Emit(entry *Entry) error {
+	emitted := *entry
+	emitted.Prefix = this.prefix
+	if len(entry.Fields) > 0 {
+		emitted.Fields = MergeFields(this.fields, entry.Fields)
+	} else {
+		emitted.Fields = this.fields
+	}
+
+	if this.sampler != nil {
+		this.summarize(emitted.Time, false)
+
+		if !this.sampler.Allow(emitted.Level, emitted.File, emitted.Line, emitted.Time) {
+			return nil
+		}
+	}
+
+	if this.dedup != nil {
+		// the deduplicator keeps the first entry of a run
+		retained := emitted
+		allowed, repeated := this.dedup.Check(&retained)
+		if repeated != nil {
+			this.Append(repeated)
+		}
+
+		if !allowed {
+			return nil
+		}
+	}
+
+	return this.Append(&emitted)
+}
+
+// Write the summary entries of the sampler
+func (this *LoggerWriter) summarize(now time.Time, force bool) {
+	for _, entry := range this.sampler.Summary(now, force) {
+		this.appendSummary(entry)
+	}
+}
+
+func (this *LoggerWriter) appendSummary(entry *Entry) error {
+	entry.Prefix = this.prefix
+
+	return this.Append(entry)
+}
+
+// Returns whether the level is allowed by this writer
+func (this *LoggerWriter) Enabled(level LogLevel) bool {
+	allowLevel, denyLevel := this.levels.get()
+
+	return level >= allowLevel && level < denyLevel
+}
+
+// Format the entry by the formatter of this writer and write it,
+// the level and package filters are not checked
+func (this *LoggerWriter) Append(entry *Entry) error {
+	// the entry writer formats the entry itself, such as the appenders of MultiLogger
+	if this.unformatted && this.entryWriter != nil {
+		unformatted := *entry
+		if this.async != nil {
+			return this.async.Write(&unformatted)
+		}
+
+		return this.entryWriter.WriteEntry(&unformatted)
+	}
+
+	record := newRecord(entry)
+	message := this.formatter.Message(record)
+	record.release()
+
+	// the entry is not kept by log.Logger
+	if this.async == nil && this.entryWriter == nil {
+		return this.Logger.Output(0, message)
+	}
+
+	formatted := *entry
+	formatted.Message = message
+
+	if this.async != nil {
+		return this.async.Write(&formatted)
+	}
 
-	data["Prefix"] = this.prefix
-	data["Level"] = ConvertLevel2String(level)
-	data["Line"] = frame.Line
-	data["PackageName"] = GetPackageName(frame.Function)
-	data["File"] = GetFileName(frame)
+	return this.output(&formatted)
+}
 
-	message := this.formatter.Message(data, args...)
+func (this *LoggerWriter) output(entry *Entry) error {
+	if this.entryWriter != nil {
+		return this.entryWriter.WriteEntry(entry)
+	}
 
-	return this.Logger.Output(0, message)
+	return this.Logger.Output(0, entry.Message)
 }
 
 /*
@@ -160,7 +436,7 @@ func (this *LoggerWriter) Output(calldepth int, s string) error {
 // Printf calls this.Output to print to the logger.
 // Arguments are handled in the manner of fmt.Printf.
 func (this *LoggerWriter) Printf(format string, v ...interface{}) {
-	this.Write(ALL, fmt.Sprintf(format, v...))
+	this.writef(nil, ALL, format, v)
 }
 
 // Print calls this.Output to print to the logger.
@@ -174,18 +450,21 @@ func (this *LoggerWriter) Println(v ...interface{}) { this.Write(ALL, fmt.Sprint
 // Fatal is equivalent to l.Print() followed by a call to os.Exit(1).
 func (this *LoggerWriter) Fatal(v ...interface{}) {
 	this.Write(ALL, fmt.Sprint(v...))
+	this.Flush()
 	os.Exit(1)
 }
 
 // Fatalf is equivalent to l.Printf() followed by a call to os.Exit(1).
 func (this *LoggerWriter) Fatalf(format string, v ...interface{}) {
-	this.Write(ALL, fmt.Sprintf(format, v...))
+	this.writef(nil, ALL, format, v)
+	this.Flush()
 	os.Exit(1)
 }
 
 // Fatalln is equivalent to l.Println() followed by a call to os.Exit(1).
 func (this *LoggerWriter) Fatalln(v ...interface{}) {
 	this.Write(ALL, fmt.Sprintln(v...))
+	this.Flush()
 	os.Exit(1)
 }
 
@@ -225,29 +504,30 @@ func (this *LoggerWriter) SetPrefix(prefix string) {
 Implement Writer
 */
 func (this *LoggerWriter) Tracef(format string, args ...interface{}) {
-	this.Write(TRACE, fmt.Sprintf(format, args...))
+	this.writef(nil, TRACE, format, args)
 }
 
 func (this *LoggerWriter) Debugf(format string, args ...interface{}) {
-	this.Write(DEBUG, fmt.Sprintf(format, args...))
+	this.writef(nil, DEBUG, format, args)
 }
 
 func (this *LoggerWriter) Infof(format string, args ...interface{}) {
-	this.Write(INFO, fmt.Sprintf(format, args...))
+	this.writef(nil, INFO, format, args)
 }
 
 func (this *LoggerWriter) Warnf(format string, args ...interface{}) {
-	this.Write(WARN, fmt.Sprintf(format, args...))
+	this.writef(nil, WARN, format, args)
 }
 
 func (this *LoggerWriter) Errorf(format string, args ...interface{}) {
-	this.Write(ERROR, fmt.Sprintf(format, args...))
+	this.writef(nil, ERROR, format, args)
 }
 
 func (this *LoggerWriter) FatalfWithExit(exit bool, format string, args ...interface{}) {
-	this.Write(FATAL, fmt.Sprintf(format, args...))
+	this.writef(nil, FATAL, format, args)
 
 	if exit {
+		this.flushBeforeExit()
 		os.Exit(1)
 	}
 }
@@ -277,6 +557,68 @@ func (this *LoggerWriter) FatalWithExit(exit bool, args ...interface{}) {
 	this.Write(FATAL, args...)
 
 	if exit {
+		this.flushBeforeExit()
+		os.Exit(1)
+	}
+}
+
+/*
+Implement Writer with context.Context
+*/
+func (this *LoggerWriter) TracefCtx(ctx context.Context, format string, args ...interface{}) {
+	this.writef(ctx, TRACE, format, args)
+}
+
+func (this *LoggerWriter) DebugfCtx(ctx context.Context, format string, args ...interface{}) {
+	this.writef(ctx, DEBUG, format, args)
+}
+
+func (this *LoggerWriter) InfofCtx(ctx context.Context, format string, args ...interface{}) {
+	this.writef(ctx, INFO, format, args)
+}
+
+func (this *LoggerWriter) WarnfCtx(ctx context.Context, format string, args ...interface{}) {
+	this.writef(ctx, WARN, format, args)
+}
+
+func (this *LoggerWriter) ErrorfCtx(ctx context.Context, format string, args ...interface{}) {
+	this.writef(ctx, ERROR, format, args)
+}
+
+func (this *LoggerWriter) FatalfCtxWithExit(ctx context.Context, exit bool, format string, args ...interface{}) {
+	this.writef(ctx, FATAL, format, args)
+
+	if exit {
+		this.flushBeforeExit()
+		os.Exit(1)
+	}
+}
+
+func (this *LoggerWriter) TraceCtx(ctx context.Context, args ...interface{}) {
+	this.WriteCtx(ctx, TRACE, args...)
+}
+
+func (this *LoggerWriter) DebugCtx(ctx context.Context, args ...interface{}) {
+	this.WriteCtx(ctx, DEBUG, args...)
+}
+
+func (this *LoggerWriter) InfoCtx(ctx context.Context, args ...interface{}) {
+	this.WriteCtx(ctx, INFO, args...)
+}
+
+func (this *LoggerWriter) WarnCtx(ctx context.Context, args ...interface{}) {
+	this.WriteCtx(ctx, WARN, args...)
+}
+
+func (this *LoggerWriter) ErrorCtx(ctx context.Context, args ...interface{}) {
+	this.WriteCtx(ctx, ERROR, args...)
+}
+
+func (this *LoggerWriter) FatalCtxWithExit(ctx context.Context, exit bool, args ...interface{}) {
+	this.WriteCtx(ctx, FATAL, args...)
+
+	if exit {
+		this.flushBeforeExit()
 		os.Exit(1)
 	}
 }
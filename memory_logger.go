@@ -0,0 +1,201 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package logger
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultMemoryCapacity = 1000
+
+// MemoryLogger keeps the last entries in a ring buffer for diagnostics.
+// As a flight recorder it dumps the entries to another logger when an entry
+// of the dump level is written, and starts recording again
+type MemoryLogger struct {
+	*LoggerWriter
+
+	mutex      sync.Mutex // guard entries
+	entries    []*Entry   // ring buffer
+	head       int
+	count      int
+	dumpWriter Writer
+	dumpLevel  LogLevel
+}
+
+// Keep the last capacity entries, the default capacity is used if it is not positive
+func NewMemoryLogger(level LogLevel, capacity int) *MemoryLogger {
+	if capacity <= 0 {
+		capacity = DefaultMemoryCapacity
+	}
+
+	memoryLogger := &MemoryLogger{
+		entries:   make([]*Entry, capacity),
+		dumpLevel: OFF,
+	}
+
+	memoryLogger.LoggerWriter = NewLoggerWriter(ioutil.Discard, level)
+	memoryLogger.SetEntryWriter(memoryLogger)
+
+	return memoryLogger
+}
+
+// Create with the dump logger defined by v, writers are the initialized loggers by name
+func NewMemoryLoggerWithConfig(v Logger, writers map[string]Writer) (*MemoryLogger, error) {
+	memoryLogger := NewMemoryLogger(ConvertString2Level(v.Level.Allow), v.Capacity)
+	memoryLogger.SetDenyLevel(ConvertString2Level(v.Level.Deny))
+
+	if v.DumpTo != "" {
+		writer := writers[v.DumpTo]
+		if writer == nil {
+			return nil, fmt.Errorf("logger %s: undefined dump logger %s", v.Name, v.DumpTo)
+		}
+
+		dumpLevel := LogLevel(ERROR)
+		if v.DumpLevel != "" {
+			dumpLevel = ConvertString2Level(v.DumpLevel)
+		}
+
+		memoryLogger.SetDump(writer, dumpLevel)
+	}
+
+	return memoryLogger, nil
+}
+
+// Dump the entries to writer when an entry of level or higher is written
+func (this *MemoryLogger) SetDump(writer Writer, level LogLevel) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.dumpWriter = writer
+	this.dumpLevel = level
+}
+
+// Implement interface EntryWriter
+func (this *MemoryLogger) WriteEntry(entry *Entry) error {
+	this.mutex.Lock()
+
+	// the entries written back through the dump logger are ignored
+	if entry.dumped {
+		this.mutex.Unlock()
+		return nil
+	}
+
+	size := len(this.entries)
+	if this.count == size {
+		this.entries[this.head] = entry
+		this.head = (this.head + 1) % size
+	} else {
+		this.entries[(this.head+this.count)%size] = entry
+		this.count++
+	}
+
+	dump := this.dumpWriter != nil && entry.Level >= this.dumpLevel
+
+	this.mutex.Unlock()
+
+	if dump {
+		return this.Dump()
+	}
+
+	return nil
+}
+
+// Write the entries to the dump logger and clear them
+func (this *MemoryLogger) Dump() error {
+	this.mutex.Lock()
+	if this.dumpWriter == nil {
+		this.mutex.Unlock()
+		return nil
+	}
+
+	var (
+		writer  = this.dumpWriter
+		entries = this.snapshot()
+	)
+	this.clear()
+	this.mutex.Unlock()
+
+	var errs MultiError
+	for _, entry := range entries {
+		dumped := *entry
+		dumped.dumped = true
+
+		err := writer.Append(&dumped)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs.ErrorOrNil()
+}
+
+// Returns the entries from the oldest to the latest
+func (this *MemoryLogger) Snapshot() []*Entry {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	return this.snapshot()
+}
+
+// Returns the entries of level or higher, of the package or its sub packages and written since,
+// the empty packageName and zero since match all entries
+func (this *MemoryLogger) Query(level LogLevel, packageName string, since time.Time) []*Entry {
+	var entries []*Entry
+
+	for _, entry := range this.Snapshot() {
+		if entry.Level < level {
+			continue
+		}
+
+		if packageName != "" && entry.PackageName != packageName && !strings.HasPrefix(entry.PackageName, packageName+"/") {
+			continue
+		}
+
+		if entry.Time.Before(since) {
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+// Remove all entries
+func (this *MemoryLogger) Clear() {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.clear()
+}
+
+func (this *MemoryLogger) snapshot() []*Entry {
+	entries := make([]*Entry, this.count)
+	for i := range entries {
+		entries[i] = this.entries[(this.head+i)%len(this.entries)]
+	}
+
+	return entries
+}
+
+func (this *MemoryLogger) clear() {
+	for i := range this.entries {
+		this.entries[i] = nil
+	}
+	this.head = 0
+	this.count = 0
+}
@@ -0,0 +1,81 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package logger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryLogger(t *testing.T) {
+	var dump bytes.Buffer
+
+	dumpWriter := NewLoggerWriter(&dump, ALL)
+	dumpWriter.SetFormatter(NewTextFormatterWithFormat("%{Level} %{Message}"))
+
+	memoryLogger := NewMemoryLogger(ALL, 3)
+	memoryLogger.closeFilter = true
+	memoryLogger.SetSkipCallerDepth(4)
+	memoryLogger.SetFormatter(NewTextFormatterWithFormat("%{Message}"))
+	memoryLogger.SetDump(dumpWriter, ERROR)
+
+	memoryLogger.Trace("trace 1")
+	memoryLogger.Debug("debug 2")
+	memoryLogger.Info("info 3")
+	memoryLogger.Warn("warn 4")
+
+	messages := func(entries []*Entry) string {
+		var result []string
+		for _, entry := range entries {
+			result = append(result, entry.Message)
+		}
+
+		return strings.Join(result, ",")
+	}
+
+	if result := messages(memoryLogger.Snapshot()); result != "debug 2,info 3,warn 4" {
+		t.Errorf("unexpected snapshot %s", result)
+	}
+
+	if result := messages(memoryLogger.Query(INFO, "", time.Time{})); result != "info 3,warn 4" {
+		t.Errorf("unexpected query by level %s", result)
+	}
+
+	if result := messages(memoryLogger.Query(ALL, "github.com/ronzxy", time.Time{})); result != "debug 2,info 3,warn 4" {
+		t.Errorf("unexpected query by package %s", result)
+	}
+
+	if result := messages(memoryLogger.Query(ALL, "github.com/ronzxy/go", time.Time{})); result != "" {
+		t.Errorf("unexpected query by package prefix %s", result)
+	}
+
+	if result := messages(memoryLogger.Query(ALL, "", time.Now().Add(time.Minute))); result != "" {
+		t.Errorf("unexpected query by time %s", result)
+	}
+
+	if dump.Len() != 0 {
+		t.Errorf("unexpected dump %s", dump.String())
+	}
+
+	memoryLogger.Error("error 5")
+
+	if result := strings.TrimSpace(dump.String()); result != "INFO info 3\nWARN warn 4\nERROR error 5" {
+		t.Errorf("unexpected dump %q", result)
+	}
+
+	if len(memoryLogger.Snapshot()) != 0 {
+		t.Errorf("unexpected entries after dump %d", len(memoryLogger.Snapshot()))
+	}
+}
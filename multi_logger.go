@@ -0,0 +1,121 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package logger
+
+import (
+	"fmt"
+	"io/ioutil"
+)
+
+type multiAppender struct {
+	writer     Writer
+	allowLevel LogLevel
+	denyLevel  LogLevel
+	override   bool // use the levels instead of the levels of writer
+}
+
+func (this *multiAppender) enabled(level LogLevel) bool {
+	if this.override {
+		return level >= this.allowLevel && level < this.denyLevel
+	}
+
+	return this.writer.Enabled(level)
+}
+
+// MultiLogger writes every entry to its appenders, which format the entry by their own formatter,
+// the formatter of MultiLogger is not used.
+// With failover the entry is written to the first appender allowing its level,
+// the next ones are tried only if it returns an error. An async appender returns no error
+// of writing, so it is never failed over
+type MultiLogger struct {
+	*LoggerWriter
+
+	appenders []*multiAppender
+	failover  bool
+}
+
+func NewMultiLogger(level LogLevel, failover bool) *MultiLogger {
+	multiLogger := &MultiLogger{
+		failover: failover,
+	}
+
+	multiLogger.LoggerWriter = NewLoggerWriter(ioutil.Discard, level)
+	multiLogger.SetEntryWriter(multiLogger)
+	multiLogger.unformatted = true
+
+	return multiLogger
+}
+
+// Create with the appenders defined by v, writers are the initialized loggers by name
+func NewMultiLoggerWithConfig(v Logger, writers map[string]Writer) (*MultiLogger, error) {
+	multiLogger := NewMultiLogger(ConvertString2Level(v.Level.Allow), v.Failover)
+	multiLogger.SetDenyLevel(ConvertString2Level(v.Level.Deny))
+
+	for _, appender := range v.Appenders {
+		writer := writers[appender.Name]
+		if writer == nil {
+			return nil, fmt.Errorf("logger %s: undefined appender %s", v.Name, appender.Name)
+		}
+
+		if appender.Level.Allow != "" || appender.Level.Deny != "" {
+			allowLevel := LogLevel(ALL)
+			if appender.Level.Allow != "" {
+				allowLevel = ConvertString2Level(appender.Level.Allow)
+			}
+
+			multiLogger.AddAppenderWithLevel(writer, allowLevel, ConvertString2Level(appender.Level.Deny))
+		} else {
+			multiLogger.AddAppender(writer)
+		}
+	}
+
+	return multiLogger, nil
+}
+
+// Add an appender with its own levels
+func (this *MultiLogger) AddAppender(writer Writer) {
+	this.appenders = append(this.appenders, &multiAppender{writer: writer})
+}
+
+// Add an appender with the levels override its own levels
+func (this *MultiLogger) AddAppenderWithLevel(writer Writer, allowLevel, denyLevel LogLevel) {
+	this.appenders = append(this.appenders, &multiAppender{
+		writer:     writer,
+		allowLevel: allowLevel,
+		denyLevel:  denyLevel,
+		override:   true,
+	})
+}
+
+// Implement interface EntryWriter
+func (this *MultiLogger) WriteEntry(entry *Entry) error {
+	var errs MultiError
+
+	for _, appender := range this.appenders {
+		if !appender.enabled(entry.Level) {
+			continue
+		}
+
+		err := appender.writer.Append(entry)
+		if err == nil {
+			if this.failover {
+				return nil
+			}
+			continue
+		}
+
+		errs = append(errs, err)
+	}
+
+	return errs.ErrorOrNil()
+}
@@ -0,0 +1,173 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"path"
+	"strings"
+	"testing"
+)
+
+type failedEntryWriter struct {
+	count int
+}
+
+func (this *failedEntryWriter) WriteEntry(entry *Entry) error {
+	this.count++
+	return errors.New("failed")
+}
+
+type countFormatter struct {
+	count int
+}
+
+func (this *countFormatter) Message(record *Record) string {
+	this.count++
+	return ""
+}
+
+func TestMultiLogger(t *testing.T) {
+	var first, second bytes.Buffer
+
+	firstWriter := NewLoggerWriter(&first, ALL)
+	firstWriter.SetFormatter(NewTextFormatterWithFormat("first %{Level} %{Message}"))
+
+	secondWriter := NewLoggerWriter(&second, ALL)
+	secondWriter.SetFormatter(NewTextFormatterWithFormat("second %{Level} %{Message}"))
+
+	multiLogger := NewMultiLogger(ALL, false)
+	multiLogger.closeFilter = true
+	multiLogger.SetSkipCallerDepth(4)
+	multiLogger.AddAppender(firstWriter)
+	multiLogger.AddAppenderWithLevel(secondWriter, WARN, OFF)
+
+	// the entries are formatted by the appenders only
+	formatter := &countFormatter{}
+	multiLogger.SetFormatter(formatter)
+
+	multiLogger.Info("info")
+	multiLogger.Warn("warn")
+
+	if formatter.count != 0 {
+		t.Errorf("the entries are formatted %d times by the multi logger", formatter.count)
+	}
+
+	if result := strings.TrimSpace(first.String()); result != "first INFO info\nfirst WARN warn" {
+		t.Errorf("unexpected first output %q", result)
+	}
+
+	if result := strings.TrimSpace(second.String()); result != "second WARN warn" {
+		t.Errorf("unexpected second output %q", result)
+	}
+
+	// failover to the secondary
+	var (
+		primary  = &failedEntryWriter{}
+		fallback bytes.Buffer
+	)
+
+	primaryWriter := NewLoggerWriter(ioutil.Discard, ALL)
+	primaryWriter.SetEntryWriter(primary)
+
+	fallbackWriter := NewLoggerWriter(&fallback, ALL)
+	fallbackWriter.SetFormatter(NewTextFormatterWithFormat("%{Message}"))
+
+	failoverLogger := NewMultiLogger(ALL, true)
+	failoverLogger.closeFilter = true
+	failoverLogger.SetSkipCallerDepth(4)
+	failoverLogger.AddAppender(primaryWriter)
+	failoverLogger.AddAppender(fallbackWriter)
+
+	err := failoverLogger.Write(ERROR, "failover")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if primary.count != 1 || strings.TrimSpace(fallback.String()) != "failover" {
+		t.Errorf("unexpected failover %d %q", primary.count, fallback.String())
+	}
+}
+
+func TestMultiLoggerConfig(t *testing.T) {
+	dir, restore := isolateState(t)
+	defer restore()
+
+	c, err := NewConfigBuilder().
+		Property("LOG_PATH", dir).
+		FileLogger("all", "${LOG_PATH}/all.log", "${LOG_PATH}/all-%{i}.log").
+		Level("ALL", "").
+		Format("text", "%{Level} %{Message}").
+		FileLogger("error", "${LOG_PATH}/error.log", "${LOG_PATH}/error-%{i}.log").
+		Level("INFO", "").
+		Format("text", "%{Level} %{Message}").
+		MultiLogger("multi", false).
+		Level("ALL", "").
+		Appender("all", "", "").
+		Appender("error", "ERROR", "").
+		DefaultFilter("multi").
+		PackageFilter("github.com/ronzxy/go-logger/example", "multi").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := InitWithConfig(c); err != nil {
+		t.Fatal(err)
+	}
+
+	Debug("debug message")
+	Error("error message")
+	Flush()
+
+	for file, expected := range map[string]string{
+		"all.log":   "DEBUG debug message\nERROR error message",
+		"error.log": "ERROR error message",
+	} {
+		content, err := ioutil.ReadFile(path.Join(dir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if strings.TrimSpace(string(content)) != expected {
+			t.Errorf("unexpected content of %s: %s", file, content)
+		}
+	}
+
+	_, err = NewConfigBuilder().
+		MultiLogger("a", false).
+		Appender("b", "", "").
+		MultiLogger("b", false).
+		Appender("a", "", "").
+		Appender("missing", "", "").
+		Build()
+	if err == nil || !strings.Contains(err.Error(), "logger reference cycle a -> b -> a") || !strings.Contains(err.Error(), "undefined logger missing") {
+		t.Errorf("unexpected build error: %v", err)
+	}
+}
+
+// An async appender never returns the error of writing, it can not be failed over
+func TestMultiLoggerFailoverAsync(t *testing.T) {
+	_, err := NewConfigBuilder().
+		MemoryLogger("memory", 10).
+		Async(16, "block").
+		MultiLogger("multi", true).
+		Appender("memory", "", "").
+		DefaultFilter("multi").
+		Build()
+	if err == nil || !strings.Contains(err.Error(), "async logger memory can not be an appender with failover") {
+		t.Errorf("unexpected build error: %v", err)
+	}
+}
@@ -0,0 +1,325 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package logger
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// GetCaller -> emit -> write -> Info -> the caller
+	namedSkipCallerDepth = 5
+
+	// the NamedLogger has no own level
+	noLevel = -1
+)
+
+// The writers and level resolved for a NamedLogger from a state
+type namedRoute struct {
+	state   *state
+	writers []Writer
+	level   LogLevel
+}
+
+// NamedLogger is the logger of a component, like the categories of log4j.
+// Its name instead of the caller package is matched against the package filters,
+// the result is cached until the config or writers are changed
+type NamedLogger struct {
+	name  string
+	level int32 // set by SetLevel
+
+	mutex sync.Mutex // guard route
+	route *namedRoute
+}
+
+var namedLoggers = struct {
+	sync.Mutex
+	loggers map[string]*NamedLogger
+}{loggers: map[string]*NamedLogger{}}
+
+// Returns the logger of name, such as the package or component name.
+// The same instance is returned for the same name
+func GetLogger(name string) *NamedLogger {
+	namedLoggers.Lock()
+	defer namedLoggers.Unlock()
+
+	logger := namedLoggers.loggers[name]
+	if logger == nil {
+		logger = &NamedLogger{name: name, level: noLevel}
+		namedLoggers.loggers[name] = logger
+	}
+
+	return logger
+}
+
+func (this *NamedLogger) Name() string {
+	return this.name
+}
+
+// Set the level of this logger, it takes precedence over the package level and the level of the filter,
+// the allow levels of the writers still apply
+func (this *NamedLogger) SetLevel(level LogLevel) {
+	atomic.StoreInt32(&this.level, int32(level))
+}
+
+// Remove the level set by SetLevel
+func (this *NamedLogger) ResetLevel() {
+	atomic.StoreInt32(&this.level, noLevel)
+}
+
+// Returns whether an entry of level is written by any writer
+func (this *NamedLogger) Enabled(level LogLevel) bool {
+	route := this.resolve()
+	for _, writer := range route.writers {
+		if this.enabled(route, writer, level) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Wait until the queued entries of the writers are written
+func (this *NamedLogger) Flush() {
+	for _, writer := range this.resolve().writers {
+		writer.Flush()
+	}
+}
+
+// Returns the writers of this logger, resolved again if the config or writers are changed
+func (this *NamedLogger) resolve() *namedRoute {
+	s := loadState()
+	if !s.initialized {
+		return &namedRoute{writers: []Writer{DefaultConsoleLogger()}, level: ALL}
+	}
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if this.route != nil && this.route.state == s {
+		return this.route
+	}
+
+	var (
+		packageRoute = getPackageRoute(s.config, this.name)
+		route        = &namedRoute{state: s, level: packageRoute.level}
+	)
+
+	for _, v := range s.config.Loggers {
+		if writer := s.writers[v.Name]; writer != nil && packageRoute.loggers[v.Name] {
+			route.writers = append(route.writers, writer)
+		}
+	}
+
+	this.route = route
+
+	return route
+}
+
+// The level of this logger is checked besides the allow level of the writer,
+// the level set by SetPackageLevel overrides both the allow level and the level of the filter
+func (this *NamedLogger) enabled(route *namedRoute, writer Writer, level LogLevel) bool {
+	allowLevel, denyLevel := writer.Levels()
+	if level >= denyLevel {
+		return false
+	}
+
+	if own := LogLevel(atomic.LoadInt32(&this.level)); own != noLevel {
+		return level >= allowLevel && level >= own
+	}
+
+	if packageLevel, ok := lookupPackageLevel(this.name); ok {
+		return level >= packageLevel
+	}
+
+	return level >= allowLevel && level >= route.level
+}
+
+func (this *NamedLogger) Write(level LogLevel, args ...interface{}) error {
+	return this.write(nil, level, args...)
+}
+
+// Write with the values extracted from ctx by the registered ContextExtractor
+func (this *NamedLogger) WriteCtx(ctx context.Context, level LogLevel, args ...interface{}) error {
+	return this.write(ctx, level, args...)
+}
+
+func (this *NamedLogger) write(ctx context.Context, level LogLevel, args ...interface{}) error {
+	if len(args) <= 0 {
+		return fmt.Errorf("empty args")
+	}
+
+	return this.emit(ctx, level, false, "", args)
+}
+
+// Write the entry of the *f methods, the args are formatted only if any writer is enabled
+func (this *NamedLogger) writef(ctx context.Context, level LogLevel, format string, args []interface{}) error {
+	return this.emit(ctx, level, true, format, args)
+}
+
+func (this *NamedLogger) emit(ctx context.Context, level LogLevel, printf bool, format string, args []interface{}) error {
+	var (
+		route = this.resolve()
+		entry *Entry
+		errs  MultiError
+	)
+
+	for _, writer := range route.writers {
+		if !this.enabled(route, writer, level) {
+			continue
+		}
+
+		// the caller is located and the args are formatted only if any writer is enabled
+		if entry == nil {
+			if printf {
+				args = []interface{}{fmt.Sprintf(format, args...)}
+			} else {
+				args = append([]interface{}(nil), args...)
+			}
+
+			frame := GetCaller(namedSkipCallerDepth)
+			entry = &Entry{
+				Time:        time.Now(),
+				Level:       level,
+				PackageName: GetPackageName(frame.Function),
+				Function:    frame.Function,
+				File:        GetFileName(frame),
+				Line:        frame.Line,
+				GoroutineID: captureGoroutineID(),
+				Ctx:         ExtractContext(ctx),
+				Args:        args,
+			}
+		}
+
+		err := writer.Emit(entry)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs.ErrorOrNil()
+}
+
+// ALL < TRACE < DEBUG < INFO < WARN < ERROR < FATAL < OFF
+func (this *NamedLogger) Trace(args ...interface{}) {
+	this.write(nil, TRACE, args...)
+}
+
+func (this *NamedLogger) Debug(args ...interface{}) {
+	this.write(nil, DEBUG, args...)
+}
+
+func (this *NamedLogger) Info(args ...interface{}) {
+	this.write(nil, INFO, args...)
+}
+
+func (this *NamedLogger) Warn(args ...interface{}) {
+	this.write(nil, WARN, args...)
+}
+
+func (this *NamedLogger) Error(args ...interface{}) {
+	this.write(nil, ERROR, args...)
+}
+
+// Fatal writes the entry, flushes the writers then calls os.Exit(1)
+func (this *NamedLogger) Fatal(args ...interface{}) {
+	this.write(nil, FATAL, args...)
+	this.Flush()
+	os.Exit(1)
+}
+
+func (this *NamedLogger) Tracef(format string, args ...interface{}) {
+	this.writef(nil, TRACE, format, args)
+}
+
+func (this *NamedLogger) Debugf(format string, args ...interface{}) {
+	this.writef(nil, DEBUG, format, args)
+}
+
+func (this *NamedLogger) Infof(format string, args ...interface{}) {
+	this.writef(nil, INFO, format, args)
+}
+
+func (this *NamedLogger) Warnf(format string, args ...interface{}) {
+	this.writef(nil, WARN, format, args)
+}
+
+func (this *NamedLogger) Errorf(format string, args ...interface{}) {
+	this.writef(nil, ERROR, format, args)
+}
+
+// Fatalf writes the entry, flushes the writers then calls os.Exit(1)
+func (this *NamedLogger) Fatalf(format string, args ...interface{}) {
+	this.writef(nil, FATAL, format, args)
+	this.Flush()
+	os.Exit(1)
+}
+
+func (this *NamedLogger) TraceCtx(ctx context.Context, args ...interface{}) {
+	this.write(ctx, TRACE, args...)
+}
+
+func (this *NamedLogger) DebugCtx(ctx context.Context, args ...interface{}) {
+	this.write(ctx, DEBUG, args...)
+}
+
+func (this *NamedLogger) InfoCtx(ctx context.Context, args ...interface{}) {
+	this.write(ctx, INFO, args...)
+}
+
+func (this *NamedLogger) WarnCtx(ctx context.Context, args ...interface{}) {
+	this.write(ctx, WARN, args...)
+}
+
+func (this *NamedLogger) ErrorCtx(ctx context.Context, args ...interface{}) {
+	this.write(ctx, ERROR, args...)
+}
+
+// FatalCtx writes the entry, flushes the writers then calls os.Exit(1)
+func (this *NamedLogger) FatalCtx(ctx context.Context, args ...interface{}) {
+	this.write(ctx, FATAL, args...)
+	this.Flush()
+	os.Exit(1)
+}
+
+func (this *NamedLogger) TracefCtx(ctx context.Context, format string, args ...interface{}) {
+	this.writef(ctx, TRACE, format, args)
+}
+
+func (this *NamedLogger) DebugfCtx(ctx context.Context, format string, args ...interface{}) {
+	this.writef(ctx, DEBUG, format, args)
+}
+
+func (this *NamedLogger) InfofCtx(ctx context.Context, format string, args ...interface{}) {
+	this.writef(ctx, INFO, format, args)
+}
+
+func (this *NamedLogger) WarnfCtx(ctx context.Context, format string, args ...interface{}) {
+	this.writef(ctx, WARN, format, args)
+}
+
+func (this *NamedLogger) ErrorfCtx(ctx context.Context, format string, args ...interface{}) {
+	this.writef(ctx, ERROR, format, args)
+}
+
+// FatalfCtx writes the entry, flushes the writers then calls os.Exit(1)
+func (this *NamedLogger) FatalfCtx(ctx context.Context, format string, args ...interface{}) {
+	this.writef(ctx, FATAL, format, args)
+	this.Flush()
+	os.Exit(1)
+}
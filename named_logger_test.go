@@ -0,0 +1,159 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package logger
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestNamedLogger(t *testing.T) {
+	_, restore := isolateState(t)
+	defer restore()
+
+	additivity := false
+
+	c, err := NewConfigBuilder().
+		MemoryLogger("all", 10).
+		Level("ALL", "").
+		Format("text", "%{Message}").
+		MemoryLogger("db", 10).
+		Level("WARN", "").
+		Format("text", "%{Message}").
+		DefaultFilter("all").
+		PackageFilter("app/db", "db").
+		Filter(Filter{Name: "app/cache", Level: "ERROR", Additivity: &additivity, Loggers: []string{"all"}}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := InitWithConfig(c); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := func(name string) string {
+		var result []string
+		for _, entry := range GetWriter(name).(*MemoryLogger).Snapshot() {
+			result = append(result, entry.Message)
+		}
+
+		return strings.Join(result, ",")
+	}
+
+	db := GetLogger("app/db/sql")
+	if GetLogger("app/db/sql") != db {
+		t.Error("unexpected new instance")
+	}
+
+	db.Info("db info")
+	db.Warnf("db %s", "warn")
+
+	cache := GetLogger("app/cache")
+	cache.Warn("cache warn")
+	cache.Error("cache error")
+
+	db.SetLevel(DEBUG)
+	db.Debug("db debug")
+	db.ResetLevel()
+	db.Debug("db debug again")
+
+	if result := messages("all"); result != "db info,db warn,cache error,db debug,db debug again" {
+		t.Errorf("unexpected all %s", result)
+	}
+
+	// the level of the named logger does not replace the level of the writer
+	if result := messages("db"); result != "db warn" {
+		t.Errorf("unexpected db %s", result)
+	}
+
+	if entry := GetWriter("db").(*MemoryLogger).Snapshot()[0]; entry.File != "named_logger_test.go" || entry.PackageName != "github.com/ronzxy/go-logger" {
+		t.Errorf("unexpected caller %s %s", entry.File, entry.PackageName)
+	}
+
+	if !db.Enabled(TRACE) || cache.Enabled(WARN) || !cache.Enabled(ERROR) {
+		t.Error("unexpected enabled")
+	}
+
+	// the package level overrides the WARN level of the db writer
+	SetPackageLevel("app/db", DEBUG)
+	db.Debug("db debug package")
+	ResetPackageLevel("app/db")
+
+	if result := messages("db"); result != "db warn,db debug package" {
+		t.Errorf("unexpected db with package level %s", result)
+	}
+
+	// resolved again after the config is changed
+	c, err = NewConfigBuilder().
+		MemoryLogger("other", 10).
+		Level("ALL", "").
+		DefaultFilter("other").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := InitWithConfig(c); err != nil {
+		t.Fatal(err)
+	}
+
+	cache.Warn("cache warn")
+
+	if entries := GetWriter("other").(*MemoryLogger).Snapshot(); len(entries) != 1 {
+		t.Errorf("unexpected entries %d", len(entries))
+	}
+}
+
+func TestNamedLoggerFatalCtx(t *testing.T) {
+	if mode := os.Getenv("LOGGER_TEST_FATAL"); mode != "" {
+		c, err := NewConfigBuilder().
+			ConsoleLogger("console").
+			Level("ALL", "").
+			Format("text", "%{Level} %{Message}").
+			DefaultFilter("console").
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := InitWithConfig(c); err != nil {
+			t.Fatal(err)
+		}
+
+		if mode == "printf" {
+			GetLogger("app").FatalfCtx(context.Background(), "fatal %d", 1)
+		} else {
+			GetLogger("app").FatalCtx(context.Background(), "fatal ", 1)
+		}
+
+		return
+	}
+
+	for _, mode := range []string{"print", "printf"} {
+		cmd := exec.Command(os.Args[0], "-test.run", "^TestNamedLoggerFatalCtx$")
+		cmd.Env = append(os.Environ(), "LOGGER_TEST_FATAL="+mode)
+
+		output, err := cmd.Output()
+		if e, ok := err.(*exec.ExitError); !ok || e.ExitCode() != 1 {
+			t.Errorf("%s: unexpected exit %v", mode, err)
+		}
+
+		if result := strings.TrimSpace(string(output)); result != "FATAL fatal 1" {
+			t.Errorf("%s: unexpected output %q", mode, result)
+		}
+	}
+}
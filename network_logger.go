@@ -0,0 +1,477 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package logger
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Framings of the lines written by NetworkLogger over stream connections
+const (
+	// Terminate every line by \n
+	FramingNewline = "newline"
+	// Prefix every line by its length as 4 bytes big endian
+	FramingLength = "length"
+
+	DefaultSpoolSize = 100 // MB
+)
+
+var (
+	networkNetworks = []string{"tcp", "tcp4", "tcp6", "tls", "udp", "udp4", "udp6"}
+	networkFramings = []string{"", FramingNewline, FramingLength}
+
+	networkDialTimeout  = 5 * time.Second
+	networkWriteTimeout = 10 * time.Second
+	// the backoff of reconnecting doubles from min to max
+	networkMinBackoff = 500 * time.Millisecond
+	networkMaxBackoff = time.Minute
+	// the bytes of spooled records replayed per lock, the writes wait for a chunk at most
+	networkReplayChunk int64 = 64 * 1024
+)
+
+// NetworkLogger streams formatted lines to a collector over tcp, tls or udp.
+// When the collector is unreachable it reconnects with exponential backoff,
+// the lines are appended to the spool file if set and replayed in order after reconnected
+type NetworkLogger struct {
+	*LoggerWriter
+
+	network    string
+	address    string
+	framing    string
+	tlsConfig  *tls.Config
+	spoolSize  int64 // bytes
+	spool      *os.File
+	spoolBytes int64
+	conn       net.Conn
+	mutex      sync.Mutex // guard conn and spool
+	connecting bool
+	closed     bool
+	stop       chan struct{}
+}
+
+// Connect to the collector at address, network is tcp, tls or udp.
+// Entries are spooled to spoolFile while disconnected if it is not empty
+func NewNetworkLogger(level LogLevel, network, address, spoolFile string) (*NetworkLogger, error) {
+	if !containsString(networkNetworks, network, true) {
+		return nil, fmt.Errorf("unsupported network %s", network)
+	}
+
+	networkLogger := &NetworkLogger{
+		network:   network,
+		address:   address,
+		framing:   FramingNewline,
+		spoolSize: DefaultSpoolSize * 1024 * 1024,
+		stop:      make(chan struct{}),
+	}
+
+	if spoolFile != "" {
+		err := networkLogger.openSpool(spoolFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	networkLogger.LoggerWriter = NewLoggerWriter(ioutil.Discard, level)
+	networkLogger.SetEntryWriter(networkLogger)
+
+	// connect in background if the collector is unreachable now
+	conn, err := networkLogger.dial()
+	if err == nil {
+		err = networkLogger.replay(conn)
+	}
+	if err != nil {
+		networkLogger.mutex.Lock()
+		networkLogger.connecting = true
+		networkLogger.mutex.Unlock()
+
+		go networkLogger.connect(networkMinBackoff)
+	}
+
+	return networkLogger, nil
+}
+
+func NewNetworkLoggerWithConfig(v Logger) (*NetworkLogger, error) {
+	networkLogger, err := NewNetworkLogger(ConvertString2Level(v.Level.Allow), v.Network,
+		VariableReplaceByConfig(v.Address), VariableReplaceByConfig(v.SpoolFile))
+	if err != nil {
+		return nil, err
+	}
+
+	networkLogger.SetDenyLevel(ConvertString2Level(v.Level.Deny))
+
+	if v.Framing != "" {
+		networkLogger.SetFraming(v.Framing)
+	}
+
+	if v.SpoolSize > 0 {
+		networkLogger.SetSpoolSize(v.SpoolSize)
+	}
+
+	return networkLogger, nil
+}
+
+// Set the framing of stream connections, newline or length
+func (this *NetworkLogger) SetFraming(framing string) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.framing = strings.ToLower(framing)
+}
+
+// Set the max size of the spool file in MB
+func (this *NetworkLogger) SetSpoolSize(size int) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.spoolSize = int64(size) * 1024 * 1024
+}
+
+// Set the config of tls connections, used by the next connection
+func (this *NetworkLogger) SetTLSConfig(tlsConfig *tls.Config) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.tlsConfig = tlsConfig
+}
+
+// Returns whether the logger is connected to the collector
+func (this *NetworkLogger) Connected() bool {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	return this.conn != nil
+}
+
+// Implement interface EntryWriter
+func (this *NetworkLogger) WriteEntry(entry *Entry) error {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if this.closed {
+		return errors.New("network logger is closed")
+	}
+
+	record := this.frame(entry.Message)
+
+	if this.conn != nil {
+		err := this.writeRecord(this.conn, record)
+		if err == nil {
+			return nil
+		}
+
+		this.disconnect()
+		if this.spool == nil {
+			return err
+		}
+	}
+
+	if this.spool == nil {
+		return fmt.Errorf("network %s %s is disconnected", this.network, this.address)
+	}
+
+	return this.spoolRecord(record)
+}
+
+// Write the queued entries and close the connection, the spooled entries are kept in the spool file
+func (this *NetworkLogger) Close() error {
+	err := this.LoggerWriter.Close()
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if this.closed {
+		return err
+	}
+	this.closed = true
+	close(this.stop)
+
+	var errs MultiError
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	if this.conn != nil {
+		err = this.conn.Close()
+		if err != nil {
+			errs = append(errs, err)
+		}
+		this.conn = nil
+	}
+
+	if this.spool != nil {
+		err = this.spool.Close()
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs.ErrorOrNil()
+}
+
+func (this *NetworkLogger) frame(message string) []byte {
+	message = strings.TrimRight(message, "\n")
+
+	// udp sends a line per datagram
+	if strings.HasPrefix(this.network, "udp") {
+		return []byte(message)
+	}
+
+	if this.framing == FramingLength {
+		record := make([]byte, 4+len(message))
+		binary.BigEndian.PutUint32(record, uint32(len(message)))
+		copy(record[4:], message)
+
+		return record
+	}
+
+	return []byte(message + "\n")
+}
+
+func (this *NetworkLogger) writeRecord(conn net.Conn, record []byte) error {
+	conn.SetWriteDeadline(time.Now().Add(networkWriteTimeout))
+	_, err := conn.Write(record)
+
+	return err
+}
+
+func (this *NetworkLogger) dial() (net.Conn, error) {
+	this.mutex.Lock()
+	tlsConfig := this.tlsConfig
+	this.mutex.Unlock()
+
+	dialer := &net.Dialer{Timeout: networkDialTimeout}
+
+	if this.network == "tls" {
+		return tls.DialWithDialer(dialer, "tcp", this.address, tlsConfig)
+	}
+
+	return dialer.Dial(this.network, this.address)
+}
+
+// Close the connection and reconnect in background, must hold the mutex
+func (this *NetworkLogger) disconnect() {
+	this.conn.Close()
+	this.conn = nil
+
+	if !this.connecting {
+		this.connecting = true
+		go this.connect(networkMinBackoff)
+	}
+}
+
+// Dial until connected, the spooled records are replayed before the connection is used
+func (this *NetworkLogger) connect(backoff time.Duration) {
+	for {
+		select {
+		case <-this.stop:
+			return
+		case <-time.After(backoff):
+		}
+
+		conn, err := this.dial()
+		if err == nil {
+			err = this.replay(conn)
+			if err == nil {
+				return
+			}
+		}
+
+		backoff *= 2
+		if backoff > networkMaxBackoff {
+			backoff = networkMaxBackoff
+		}
+	}
+}
+
+// Write the spooled records to conn and use it if all records are written,
+// keep the records not written in the spool file.
+// The records are replayed in chunks, the mutex is released between the chunks,
+// the records spooled meanwhile are appended and replayed in order
+func (this *NetworkLogger) replay(conn net.Conn) error {
+	var offset int64
+
+	for {
+		done, err := this.replayChunk(conn, &offset)
+		if err != nil || done {
+			return err
+		}
+	}
+}
+
+// Replay a chunk of records from offset, the connection is used if all records are written
+func (this *NetworkLogger) replayChunk(conn net.Conn, offset *int64) (bool, error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if this.closed {
+		conn.Close()
+		return true, nil
+	}
+
+	if this.spool != nil && *offset < this.spoolBytes {
+		var err error
+
+		*offset, err = this.replaySpool(conn, *offset, networkReplayChunk)
+		if err != nil {
+			conn.Close()
+
+			if *offset > 0 {
+				if e := this.compactSpool(*offset); e != nil {
+					return true, e
+				}
+			}
+
+			return true, err
+		}
+
+		if *offset < this.spoolBytes {
+			return false, nil
+		}
+
+		err = this.truncateSpool()
+		if err != nil {
+			conn.Close()
+			return true, err
+		}
+	}
+
+	this.conn = conn
+	this.connecting = false
+
+	return true, nil
+}
+
+func (this *NetworkLogger) openSpool(spoolFile string) error {
+	err := os.MkdirAll(filepath.Dir(spoolFile), 0755)
+	if err != nil {
+		return err
+	}
+
+	this.spool, err = os.OpenFile(spoolFile, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	fileInfo, err := this.spool.Stat()
+	if err != nil {
+		this.spool.Close()
+		return err
+	}
+	this.spoolBytes = fileInfo.Size()
+
+	return nil
+}
+
+// Append the record with its length to the spool file
+func (this *NetworkLogger) spoolRecord(record []byte) error {
+	size := int64(4 + len(record))
+	if this.spoolSize > 0 && this.spoolBytes+size > this.spoolSize {
+		return fmt.Errorf("spool file %s is full", this.spool.Name())
+	}
+
+	data := make([]byte, size)
+	binary.BigEndian.PutUint32(data, uint32(len(record)))
+	copy(data[4:], record)
+
+	_, err := this.spool.Write(data)
+	if err != nil {
+		return err
+	}
+	this.spoolBytes += size
+
+	return nil
+}
+
+// Write the records from offset until about limit bytes are written, returns the offset of the records written
+func (this *NetworkLogger) replaySpool(conn net.Conn, offset, limit int64) (int64, error) {
+	_, err := this.spool.Seek(offset, io.SeekStart)
+	if err != nil {
+		return offset, err
+	}
+
+	var (
+		reader = bufio.NewReader(this.spool)
+		header = make([]byte, 4)
+		end    = offset + limit
+	)
+
+	for offset < this.spoolBytes && offset < end {
+		_, err = io.ReadFull(reader, header)
+		if err != nil {
+			return offset, err
+		}
+
+		record := make([]byte, binary.BigEndian.Uint32(header))
+		_, err = io.ReadFull(reader, record)
+		if err != nil {
+			return offset, err
+		}
+
+		err = this.writeRecord(conn, record)
+		if err != nil {
+			return offset, err
+		}
+
+		offset += int64(4 + len(record))
+	}
+
+	return offset, nil
+}
+
+// Remove the records before offset from the spool file
+func (this *NetworkLogger) compactSpool(offset int64) error {
+	_, err := this.spool.Seek(offset, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	remain, err := ioutil.ReadAll(this.spool)
+	if err != nil {
+		return err
+	}
+
+	err = this.truncateSpool()
+	if err != nil {
+		return err
+	}
+
+	_, err = this.spool.Write(remain)
+	if err != nil {
+		return err
+	}
+	this.spoolBytes = int64(len(remain))
+
+	return nil
+}
+
+func (this *NetworkLogger) truncateSpool() error {
+	err := this.spool.Truncate(0)
+	if err != nil {
+		return err
+	}
+	this.spoolBytes = 0
+
+	return nil
+}
@@ -0,0 +1,151 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package logger
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestNetworkLogger(t *testing.T, address, spoolFile string) *NetworkLogger {
+	networkLogger, err := NewNetworkLogger(ALL, "tcp", address, spoolFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	networkLogger.closeFilter = true
+	networkLogger.SetSkipCallerDepth(4)
+	networkLogger.SetFormatter(NewTextFormatterWithFormat("%{Message}"))
+
+	return networkLogger
+}
+
+func acceptConn(t *testing.T, listener net.Listener) net.Conn {
+	listener.(*net.TCPListener).SetDeadline(time.Now().Add(5 * time.Second))
+
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	return conn
+}
+
+func TestNetworkLogger(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	networkLogger := newTestNetworkLogger(t, listener.Addr().String(), "")
+	defer networkLogger.Close()
+
+	networkLogger.SetFraming(FramingLength)
+	networkLogger.Info("hello")
+	networkLogger.Info("network")
+
+	conn := acceptConn(t, listener)
+	defer conn.Close()
+
+	for _, expected := range []string{"hello", "network"} {
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			t.Fatal(err)
+		}
+
+		record := make([]byte, binary.BigEndian.Uint32(header))
+		if _, err := io.ReadFull(conn, record); err != nil {
+			t.Fatal(err)
+		}
+
+		if string(record) != expected {
+			t.Errorf("unexpected record %q", record)
+		}
+	}
+}
+
+func TestNetworkLoggerSpool(t *testing.T) {
+	defer func(backoff time.Duration) {
+		networkMinBackoff = backoff
+	}(networkMinBackoff)
+	networkMinBackoff = 10 * time.Millisecond
+
+	// replay a record per chunk
+	defer func(chunk int64) {
+		networkReplayChunk = chunk
+	}(networkReplayChunk)
+	networkReplayChunk = 1
+
+	dir, err := ioutil.TempDir("", "network")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// reserve a port that no one listens on
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	networkLogger := newTestNetworkLogger(t, address, filepath.Join(dir, "spool", "network.spool"))
+	defer networkLogger.Close()
+
+	if networkLogger.Connected() {
+		t.Fatal("unexpected connected")
+	}
+
+	for i := 0; i < 5; i++ {
+		err = networkLogger.Write(INFO, fmt.Sprintf("spooled %d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	listener, err = net.Listen("tcp", address)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer listener.Close()
+
+	conn := acceptConn(t, listener)
+	defer conn.Close()
+
+	for i := 0; i < 100 && !networkLogger.Connected(); i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+	networkLogger.Info("live")
+
+	scanner := bufio.NewScanner(conn)
+	for _, expected := range []string{"spooled 0", "spooled 1", "spooled 2", "spooled 3", "spooled 4", "live"} {
+		if !scanner.Scan() {
+			t.Fatalf("read %s error: %v", expected, scanner.Err())
+		}
+
+		if scanner.Text() != expected {
+			t.Errorf("unexpected line %q, expected %q", scanner.Text(), expected)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package logger
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ${NAME}, ${env:NAME}, ${NAME:-default} and ${env:NAME:-default}
+var propertyRegexp = regexp.MustCompile(`\$\{(env:)?([a-zA-Z_][0-9a-zA-Z_]*)(:-([^}]*))?\}`)
+
+// A property reference parsed from ${...}
+type propertyReference struct {
+	env        bool
+	name       string
+	hasDefault bool
+	value      string // default value
+}
+
+func parsePropertyReference(match []string) propertyReference {
+	return propertyReference{
+		env:        match[1] != "",
+		name:       match[2],
+		hasDefault: match[3] != "",
+		value:      match[4],
+	}
+}
+
+// Replace the property references of str by lookup,
+// the default value is used if the property is undefined or empty
+func expandProperties(str string, lookup func(name string) (string, bool)) string {
+	return propertyRegexp.ReplaceAllStringFunc(str, func(s string) string {
+		reference := parsePropertyReference(propertyRegexp.FindStringSubmatch(s))
+
+		var (
+			value string
+			ok    bool
+		)
+		if reference.env {
+			value, ok = os.LookupEnv(reference.name)
+		} else {
+			value, ok = lookup(reference.name)
+		}
+
+		if (!ok || value == "") && reference.hasDefault {
+			value = reference.value
+		}
+
+		return RemoveEnterAndSpace(value)
+	})
+}
+
+// Resolve the property references between properties in dependency order,
+// the references in a cycle are replaced by empty string and returned as error
+func ResolveProperties(properties []Property) (map[string]string, error) {
+	var (
+		raw      = map[string]string{}
+		resolved = map[string]string{}
+		visiting = map[string]bool{}
+		chain    []string
+		errs     MultiError
+		resolve  func(name string) (string, bool)
+	)
+
+	for _, v := range properties {
+		raw[v.Name] = v.Value
+	}
+
+	resolve = func(name string) (string, bool) {
+		if value, ok := resolved[name]; ok {
+			return value, true
+		}
+
+		value, ok := raw[name]
+		if !ok {
+			return "", false
+		}
+
+		if visiting[name] {
+			errs = append(errs, fmt.Errorf("property cycle %s -> %s", strings.Join(chain, " -> "), name))
+			return "", true
+		}
+
+		visiting[name] = true
+		chain = append(chain, name)
+
+		value = RemoveEnterAndSpace(expandProperties(value, resolve))
+
+		chain = chain[:len(chain)-1]
+		visiting[name] = false
+		resolved[name] = value
+
+		return value, true
+	}
+
+	names := make([]string, 0, len(raw))
+	for name := range raw {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		resolve(name)
+	}
+
+	return resolved, errs.ErrorOrNil()
+}
+
+// Set the property values of config, append the properties not defined
+func (this *Config) SetProperties(properties map[string]string) {
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		found := false
+		for i := range this.Properties {
+			if this.Properties[i].Name == name {
+				this.Properties[i].Value = properties[name]
+				found = true
+			}
+		}
+
+		if !found {
+			this.Properties = append(this.Properties, Property{Name: name, Value: properties[name]})
+		}
+	}
+}
@@ -0,0 +1,281 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package logger
+
+import (
+	"errors"
+	"github.com/robfig/cron"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+	"time"
+)
+
+// guarded by stateMutex
+var (
+	watchStop  chan struct{}
+	signalStop chan struct{}
+)
+
+// Parse the config file passed to Init again and apply the changes,
+// the writers whose definition is not changed are kept
+func Reload() error {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+
+	if configPath == "" {
+		return errors.New("logger is not initialized from config file")
+	}
+
+	newConfig, err := NewConfigWithFormat(configPath, configFormat)
+	if err != nil {
+		return err
+	}
+
+	newConfig.SetProperties(propertyOverrides)
+
+	// keep the current config if the new one is invalid in strict mode
+	err = validateConfig(newConfig)
+	if err != nil {
+		return err
+	}
+
+	reloadConfig(newConfig)
+
+	return nil
+}
+
+// Returns the writers of oldConfig whose definition is not changed in newConfig,
+// their rolling and purge jobs are registered to newJob
+func reusableWriters(oldConfig, newConfig *Config, oldWriters map[string]Writer, newJob *cron.Cron) map[string]Writer {
+	reuse := map[string]Writer{}
+
+	// Properties may be referenced by any logger, recreate all writers if changed
+	if oldConfig == nil || !reflect.DeepEqual(oldConfig.Properties, newConfig.Properties) {
+		return reuse
+	}
+
+	for name, writer := range oldWriters {
+		oldLogger := findLogger(oldConfig, name)
+		if oldLogger == nil || !reflect.DeepEqual(oldLogger, findLogger(newConfig, name)) {
+			continue
+		}
+
+		// the referenced loggers may be recreated, always recreate the logger
+		if len(oldLogger.references()) > 0 {
+			continue
+		}
+
+		reuse[name] = writer
+
+		// cron can not remove a job, register the rolling and purge to the new one
+		switch w := writer.(type) {
+		case *FileLogger:
+			addRollingJob(newJob, w)
+		case *DatabaseLogger:
+			addPurgeJob(newJob, w)
+		}
+	}
+
+	return reuse
+}
+
+// Apply newConfig, stateMutex must be held
+func reloadConfig(newConfig *Config) {
+	var (
+		oldState   = loadState()
+		oldConfig  = oldState.config
+		oldWriters = oldState.writers
+		oldJob     = job
+		newJob     = cron.New()
+		reuse      = reusableWriters(oldConfig, newConfig, oldWriters, newJob)
+	)
+
+	// the properties are used by creating the writers
+	updateState(func(s *state) {
+		s.config = newConfig
+		s.properties = initProperties(newConfig)
+	})
+
+	writers := initWriters(newConfig, reuse, newJob)
+
+	// swap writers and rolling job
+	updateState(func(s *state) {
+		s.writers = writers
+		if newConfig.Loggers != nil {
+			s.initialized = true
+		}
+	})
+	job = newJob
+
+	oldJob.Stop()
+	if rolling {
+		newJob.Start()
+	}
+
+	if newConfig.Loggers != nil && !oldState.initialized {
+		startRolling()
+	}
+
+	// close writers removed or recreated
+	for name, writer := range oldWriters {
+		if writers[name] == writer {
+			continue
+		}
+
+		err := writer.Close()
+		if err != nil {
+			Errorf("close logger %s error: %s", name, err.Error())
+		}
+	}
+
+	if oldConfig == nil || oldConfig.WatchInterval != newConfig.WatchInterval {
+		if newConfig.WatchInterval > 0 {
+			startWatchConfig(time.Duration(newConfig.WatchInterval) * time.Second)
+		} else {
+			stopWatchConfig()
+		}
+	}
+}
+
+func findLogger(c *Config, name string) *Logger {
+	for i := range c.Loggers {
+		if c.Loggers[i].Name == name {
+			return &c.Loggers[i]
+		}
+	}
+
+	return nil
+}
+
+// Check the modification time of the config file every interval,
+// reload the config file if it is changed
+func WatchConfig(interval time.Duration) {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+
+	startWatchConfig(interval)
+}
+
+func startWatchConfig(interval time.Duration) {
+	stopWatchConfig()
+
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	watchStop = make(chan struct{})
+
+	go watchConfig(configPath, interval, watchStop)
+}
+
+func StopWatchConfig() {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+
+	stopWatchConfig()
+}
+
+func stopWatchConfig() {
+	if watchStop != nil {
+		close(watchStop)
+		watchStop = nil
+	}
+}
+
+func watchConfig(file string, interval time.Duration, stop chan struct{}) {
+	var (
+		ticker  = time.NewTicker(interval)
+		modTime time.Time
+	)
+	defer ticker.Stop()
+
+	if fileInfo, err := os.Stat(file); err == nil {
+		modTime = fileInfo.ModTime()
+	}
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		fileInfo, err := os.Stat(file)
+		if err != nil {
+			Errorf("check config file error: %s", err.Error())
+			continue
+		}
+
+		if fileInfo.ModTime().Equal(modTime) {
+			continue
+		}
+		modTime = fileInfo.ModTime()
+
+		err = Reload()
+		if err != nil {
+			Errorf("reload config file error: %s", err.Error())
+		}
+	}
+}
+
+// Reload the config file when receive the signals, default is SIGHUP
+func ReloadOnSignal(signals ...os.Signal) {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+
+	stopReloadOnSignal()
+
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGHUP}
+	}
+
+	var (
+		ch   = make(chan os.Signal, 1)
+		stop = make(chan struct{})
+	)
+
+	signal.Notify(ch, signals...)
+	signalStop = stop
+
+	go func() {
+		defer signal.Stop(ch)
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ch:
+				err := Reload()
+				if err != nil {
+					Errorf("reload config file error: %s", err.Error())
+				}
+			}
+		}
+	}()
+}
+
+func StopReloadOnSignal() {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+
+	stopReloadOnSignal()
+}
+
+func stopReloadOnSignal() {
+	if signalStop != nil {
+		close(signalStop)
+		signalStop = nil
+	}
+}
@@ -0,0 +1,94 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package logger
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+	"testing"
+)
+
+const reloadTestConfig = `<?xml version="1.0" encoding="UTF-8"?>
+<Configuration>
+    <Properties>
+        <Property name="LOG_PATH">%s</Property>
+    </Properties>
+    <Loggers>
+        <Logger name="%s" target="FILE" fileName="${LOG_PATH}/%s.log" filePattern="${LOG_PATH}/%s-%%{i}.log">
+            <Format type="text">%%{Level} %%{Message}</Format>
+            <Level>
+                <Allow>INFO</Allow>
+            </Level>
+        </Logger>
+    </Loggers>
+    <Filters>
+        <DefaultFilter>
+            <Filter>
+                <Logger>%s</Logger>
+            </Filter>
+        </DefaultFilter>
+        <PackageFilter>
+            <Filter name="github.com/ronzxy/go-logger/example"/>
+        </PackageFilter>
+    </Filters>
+</Configuration>`
+
+func writeReloadTestConfig(t *testing.T, file, dir, name string) {
+	content := fmt.Sprintf(reloadTestConfig, dir, name, name, name, name)
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir, restore := isolateState(t)
+	defer restore()
+
+	configFile := path.Join(dir, "logger.xml")
+	writeReloadTestConfig(t, configFile, dir, "first")
+
+	if err := Init(configFile); err != nil {
+		t.Fatal(err)
+	}
+	first := loadState().writers["first"].(*FileLogger)
+
+	Info("before reload")
+
+	writeReloadTestConfig(t, configFile, dir, "second")
+	if err := Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if loadState().writers["first"] != nil || loadState().writers["second"] == nil {
+		t.Fatalf("unexpected writers after reload: %v", loadState().writers)
+	}
+
+	if _, err := first.writer.Write([]byte("closed")); err == nil {
+		t.Error("removed file logger is not closed")
+	}
+
+	Info("after reload")
+
+	for name, expected := range map[string]string{"first": "INFO before reload", "second": "INFO after reload"} {
+		content, err := ioutil.ReadFile(path.Join(dir, name+".log"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if strings.TrimSpace(string(content)) != expected {
+			t.Errorf("unexpected content of %s.log: %s", name, content)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package logger
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sampling types
+const (
+	// The first N entries per interval, then every Mth
+	SamplingFirst = "first"
+	// A token bucket refilled at rate per second
+	SamplingToken = "token"
+
+	DefaultSamplingInterval = 1000  // ms
+	DefaultSummaryInterval  = 60000 // ms
+)
+
+var samplingTypes = []string{"", SamplingFirst, SamplingToken}
+
+type samplerKey struct {
+	level LogLevel
+	file  string
+	line  int
+}
+
+type samplerSite struct {
+	start      time.Time // start of the interval
+	count      int       // entries in the interval
+	tokens     float64
+	last       time.Time // last entry, also the last refill of tokens
+	suppressed uint64
+}
+
+// Sampler limits the entries of every level and call site,
+// the count of suppressed entries is reported by a summary entry every summary interval,
+// by a timer in case no more entries are written
+type Sampler struct {
+	samplingType    string
+	interval        time.Duration
+	first           int
+	thereafter      int
+	rate            float64
+	burst           int
+	summaryInterval time.Duration
+
+	mutex       sync.Mutex
+	sites       map[samplerKey]*samplerSite
+	lastSummary time.Time
+	pending     uint64                   // entries suppressed since the last summary
+	timer       *time.Timer              // write the summary of the pending entries
+	output      func(entry *Entry) error // write the summary entries by timer
+	expiring    sync.WaitGroup           // the summaries being written by timer
+}
+
+// Allow the first entries per interval of every call site, then every thereafter-th,
+// thereafter 0 suppresses all the rest
+func NewFirstSampler(first, thereafter int, interval time.Duration) *Sampler {
+	if interval <= 0 {
+		interval = DefaultSamplingInterval * time.Millisecond
+	}
+
+	return newSampler(&Sampler{
+		samplingType: SamplingFirst,
+		interval:     interval,
+		first:        first,
+		thereafter:   thereafter,
+	})
+}
+
+// Allow rate entries per second of every call site, up to burst at once
+func NewTokenSampler(rate float64, burst int) *Sampler {
+	if burst <= 0 {
+		burst = 1
+	}
+
+	return newSampler(&Sampler{
+		samplingType: SamplingToken,
+		rate:         rate,
+		burst:        burst,
+	})
+}
+
+func NewSamplerWithConfig(v Sampling) *Sampler {
+	var sampler *Sampler
+
+	if strings.ToLower(v.Type) == SamplingToken {
+		sampler = NewTokenSampler(v.Rate, v.Burst)
+	} else {
+		sampler = NewFirstSampler(v.First, v.Thereafter, time.Duration(v.Interval)*time.Millisecond)
+	}
+
+	if v.SummaryInterval > 0 {
+		sampler.SetSummaryInterval(time.Duration(v.SummaryInterval) * time.Millisecond)
+	}
+
+	return sampler
+}
+
+func newSampler(sampler *Sampler) *Sampler {
+	sampler.summaryInterval = DefaultSummaryInterval * time.Millisecond
+	sampler.sites = map[samplerKey]*samplerSite{}
+	sampler.lastSummary = time.Now()
+
+	return sampler
+}
+
+func (this *Sampler) SetSummaryInterval(interval time.Duration) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.summaryInterval = interval
+}
+
+// Returns whether the entry of the call site is allowed at now
+func (this *Sampler) Allow(level LogLevel, file string, line int, now time.Time) bool {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	key := samplerKey{level: level, file: file, line: line}
+	site := this.sites[key]
+	if site == nil {
+		site = &samplerSite{start: now, tokens: float64(this.burst), last: now}
+		this.sites[key] = site
+	}
+
+	var allowed bool
+
+	switch this.samplingType {
+	case SamplingToken:
+		{
+			site.tokens += now.Sub(site.last).Seconds() * this.rate
+			if site.tokens > float64(this.burst) {
+				site.tokens = float64(this.burst)
+			}
+
+			if site.tokens >= 1 {
+				site.tokens--
+				allowed = true
+			}
+		}
+	default:
+		{
+			if now.Sub(site.start) >= this.interval {
+				site.start = now
+				site.count = 0
+			}
+			site.count++
+
+			allowed = site.count <= this.first ||
+				(this.thereafter > 0 && (site.count-this.first)%this.thereafter == 0)
+		}
+	}
+
+	site.last = now
+	if !allowed {
+		site.suppressed++
+		this.pending++
+		this.schedule(now)
+	}
+
+	return allowed
+}
+
+func (this *Sampler) setOutput(output func(entry *Entry) error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.output = output
+}
+
+// Stop the timer of the summary and wait for the summary being written by it,
+// the pending entries are reported by Summary with force
+func (this *Sampler) stop() {
+	this.mutex.Lock()
+	if this.timer != nil {
+		this.timer.Stop()
+		this.timer = nil
+	}
+	this.output = nil
+	this.mutex.Unlock()
+
+	this.expiring.Wait()
+}
+
+// Start the timer of the next summary if any entry is pending, must hold the mutex
+func (this *Sampler) schedule(now time.Time) {
+	if this.timer != nil || this.output == nil || this.pending == 0 {
+		return
+	}
+
+	delay := this.summaryInterval - now.Sub(this.lastSummary)
+	if delay < 0 {
+		delay = 0
+	}
+
+	this.timer = time.AfterFunc(delay, this.expire)
+}
+
+// Write the summary by timer, the counts are taken only if the output is not stopped
+func (this *Sampler) expire() {
+	this.mutex.Lock()
+	this.timer = nil
+	output := this.output
+	if output == nil {
+		this.mutex.Unlock()
+		return
+	}
+
+	now := time.Now()
+	entries := this.summary(now, false)
+	// summarized by a write meanwhile, wait for the next interval
+	this.schedule(now)
+	this.expiring.Add(1)
+	this.mutex.Unlock()
+
+	defer this.expiring.Done()
+
+	for _, entry := range entries {
+		output(entry)
+	}
+}
+
+// Returns the summary entries of the suppressed call sites if the summary interval elapsed,
+// or force is true
+func (this *Sampler) Summary(now time.Time, force bool) []*Entry {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	return this.summary(now, force)
+}
+
+// Summary with the mutex held
+func (this *Sampler) summary(now time.Time, force bool) []*Entry {
+	if !force && now.Sub(this.lastSummary) < this.summaryInterval {
+		return nil
+	}
+	this.lastSummary = now
+	this.pending = 0
+
+	var entries []*Entry
+	for key, site := range this.sites {
+		if site.suppressed > 0 {
+			message := fmt.Sprintf("suppressed %d messages from %s:%d", site.suppressed, key.file, key.line)
+			entries = append(entries, &Entry{
+				Time:  now,
+				Level: key.level,
+				File:  key.file,
+				Line:  key.line,
+				Args:  []interface{}{message},
+			})
+			site.suppressed = 0
+		}
+
+		// forget the idle call sites
+		if now.Sub(site.last) >= this.summaryInterval {
+			delete(this.sites, key)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].File != entries[j].File {
+			return entries[i].File < entries[j].File
+		}
+
+		return entries[i].Line < entries[j].Line
+	})
+
+	return entries
+}
@@ -0,0 +1,192 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package logger
+
+import (
+	"fmt"
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSampler(t *testing.T) {
+	var (
+		now     = time.Now()
+		sampler = NewFirstSampler(2, 3, time.Second)
+		allowed []int
+	)
+
+	for i := 1; i <= 10; i++ {
+		if sampler.Allow(WARN, "main.go", 42, now) {
+			allowed = append(allowed, i)
+		}
+	}
+
+	if len(allowed) != 4 || allowed[2] != 5 || allowed[3] != 8 {
+		t.Errorf("unexpected allowed %v", allowed)
+	}
+
+	// other level and call site are counted separately
+	if !sampler.Allow(ERROR, "main.go", 42, now) || !sampler.Allow(WARN, "main.go", 43, now) {
+		t.Error("unexpected suppressed")
+	}
+
+	if !sampler.Allow(WARN, "main.go", 42, now.Add(time.Second)) {
+		t.Error("unexpected suppressed in new interval")
+	}
+
+	if entries := sampler.Summary(now.Add(time.Second), false); len(entries) != 0 {
+		t.Errorf("unexpected summary before summary interval %d", len(entries))
+	}
+
+	entries := sampler.Summary(now.Add(2*time.Minute), false)
+	if len(entries) != 1 || entries[0].Level != WARN || entries[0].Args[0] != "suppressed 6 messages from main.go:42" {
+		t.Errorf("unexpected summary %+v", entries)
+	}
+
+	sampler = NewTokenSampler(1, 2)
+	allowed = nil
+	for i, offset := range []time.Duration{0, 0, 0, time.Second, time.Second} {
+		if sampler.Allow(INFO, "main.go", 1, now.Add(offset)) {
+			allowed = append(allowed, i)
+		}
+	}
+
+	if len(allowed) != 3 || allowed[2] != 3 {
+		t.Errorf("unexpected allowed by token %v", allowed)
+	}
+}
+
+func TestSamplerLoggerWriter(t *testing.T) {
+	var buf bytes.Buffer
+
+	writer := NewLoggerWriter(&buf, ALL)
+	writer.closeFilter = true
+	writer.SetSkipCallerDepth(4)
+	writer.SetFormatter(NewTextFormatterWithFormat("%{Level} %{Message}"))
+	writer.SetSampler(NewFirstSampler(1, 0, time.Minute))
+
+	for i := 0; i < 5; i++ {
+		writer.Warn("flood")
+	}
+	writer.Close()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || lines[0] != "WARN flood" || !strings.HasPrefix(lines[1], "WARN suppressed 4 messages from sampler_test.go:") {
+		t.Errorf("unexpected lines %q", lines)
+	}
+}
+
+type lockedBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (this *lockedBuffer) Write(p []byte) (int, error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	return this.buf.Write(p)
+}
+
+func (this *lockedBuffer) String() string {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	return this.buf.String()
+}
+
+// The summary is written by timer after the writes stop
+func TestSamplerSummaryTimer(t *testing.T) {
+	var buf lockedBuffer
+
+	sampler := NewFirstSampler(1, 0, time.Minute)
+	sampler.SetSummaryInterval(50 * time.Millisecond)
+
+	writer := NewLoggerWriter(&buf, ALL)
+	writer.closeFilter = true
+	writer.SetSkipCallerDepth(4)
+	writer.SetFormatter(NewTextFormatterWithFormat("%{Level} %{Message}"))
+	writer.SetSampler(sampler)
+	defer writer.Close()
+
+	for i := 0; i < 5; i++ {
+		writer.Warn("flood")
+	}
+
+	var lines []string
+	for i := 0; i < 100 && len(lines) < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+		lines = strings.Split(strings.TrimSpace(buf.String()), "\n")
+	}
+
+	if len(lines) != 2 || lines[0] != "WARN flood" || !strings.HasPrefix(lines[1], "WARN suppressed 4 messages from sampler_test.go:") {
+		t.Errorf("unexpected lines %q", lines)
+	}
+}
+
+// The counts taken by the timer while the sampler is stopped are still written
+func TestSamplerSummaryStop(t *testing.T) {
+	for round := 0; round < 1000; round++ {
+		var (
+			mutex   sync.Mutex
+			written uint64
+		)
+
+		count := func(entries ...*Entry) {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			for _, entry := range entries {
+				var n uint64
+				fmt.Sscanf(entry.Args[0].(string), "suppressed %d messages", &n)
+				written += n
+			}
+		}
+
+		sampler := NewFirstSampler(0, 0, time.Minute)
+		sampler.SetSummaryInterval(0)
+		sampler.setOutput(func(entry *Entry) error {
+			count(entry)
+			return nil
+		})
+
+		now := time.Now()
+		for i := 0; i < 10; i++ {
+			sampler.Allow(WARN, "sampler_test.go", i, now)
+		}
+
+		// the timer fired before stop but runs after it in the odd rounds
+		done := make(chan struct{})
+		if round%2 == 0 {
+			go func() {
+				sampler.expire()
+				close(done)
+			}()
+			sampler.stop()
+		} else {
+			sampler.stop()
+			sampler.expire()
+			close(done)
+		}
+
+		count(sampler.Summary(time.Now(), true)...)
+		<-done
+
+		if written != 10 {
+			t.Fatalf("round %d: unexpected suppressed %d", round, written)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message formats of syslog
+const (
+	SyslogFormatRFC5424 = "rfc5424"
+	SyslogFormatRFC3164 = "rfc3164"
+)
+
+var (
+	// the index is the facility code
+	syslogFacilities = []string{
+		"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+		"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+		"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+	}
+	syslogFormats  = []string{"", SyslogFormatRFC5424, SyslogFormatRFC3164}
+	syslogNetworks = []string{"", "udp", "udp4", "udp6", "tcp", "tcp4", "tcp6", "unix", "unixgram"}
+
+	// the local syslog sockets used if network and address are empty
+	syslogLocalAddresses = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+	syslogDialTimeout    = 5 * time.Second
+	syslogDial           = net.DialTimeout
+)
+
+// SyslogLogger writes log entries to syslog over udp, tcp or unix socket,
+// it connects on the first write and reconnects once when the write fails
+type SyslogLogger struct {
+	*LoggerWriter
+
+	network  string
+	address  string
+	facility int
+	appName  string
+	hostname string
+	format   string
+
+	mutex       sync.Mutex // guard the fields below
+	conn        net.Conn
+	connNetwork string        // the network of conn, the local syslog may be a datagram or stream socket
+	dialing     chan struct{} // closed when the dial in progress finishes
+	dialErr     error         // the error of the last dial
+	closed      bool
+}
+
+// Returns the logger of the syslog at address, the local syslog is used if network and address are empty.
+// The syslog is connected on the first write, so the logger is created even if the syslog is down
+func NewSyslogLogger(level LogLevel, network, address string) (*SyslogLogger, error) {
+	if network == "" && address != "" {
+		network = "udp"
+	}
+
+	syslogLogger := &SyslogLogger{
+		network:  network,
+		address:  address,
+		facility: 1, // user
+		format:   SyslogFormatRFC5424,
+	}
+
+	syslogLogger.LoggerWriter = NewLoggerWriter(ioutil.Discard, level)
+	syslogLogger.SetEntryWriter(syslogLogger)
+	syslogLogger.appName = syslogLogger.prefix
+	syslogLogger.hostname, _ = os.Hostname()
+
+	return syslogLogger, nil
+}
+
+func NewSyslogLoggerWithConfig(v Logger) (*SyslogLogger, error) {
+	syslogLogger, err := NewSyslogLogger(ConvertString2Level(v.Level.Allow), v.Network, VariableReplaceByConfig(v.Address))
+	if err != nil {
+		return nil, err
+	}
+
+	syslogLogger.SetDenyLevel(ConvertString2Level(v.Level.Deny))
+
+	if v.Facility != "" {
+		err = syslogLogger.SetFacility(v.Facility)
+		if err != nil {
+			syslogLogger.Close()
+			return nil, err
+		}
+	}
+
+	if v.AppName != "" {
+		syslogLogger.SetAppName(VariableReplaceByConfig(v.AppName))
+	}
+
+	if v.SyslogFormat != "" {
+		syslogLogger.SetSyslogFormat(v.SyslogFormat)
+	}
+
+	return syslogLogger, nil
+}
+
+// Set the facility by name, such as user, daemon or local0
+func (this *SyslogLogger) SetFacility(name string) error {
+	for i, v := range syslogFacilities {
+		if strings.EqualFold(v, name) {
+			this.facility = i
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown syslog facility %s", name)
+}
+
+func (this *SyslogLogger) SetAppName(appName string) {
+	this.appName = appName
+}
+
+// Set the message format, rfc5424 or rfc3164
+func (this *SyslogLogger) SetSyslogFormat(format string) {
+	this.format = strings.ToLower(format)
+}
+
+// Implement interface EntryWriter
+func (this *SyslogLogger) WriteEntry(entry *Entry) error {
+	var err error
+
+	// reconnect and retry once
+	for i := 0; i < 2; i++ {
+		var (
+			conn    net.Conn
+			network string
+		)
+
+		conn, network, err = this.connection()
+		if err != nil {
+			return err
+		}
+
+		_, err = io.WriteString(conn, this.message(entry, isStreamNetwork(network)))
+		if err == nil {
+			return nil
+		}
+
+		this.drop(conn)
+	}
+
+	return err
+}
+
+// Write the queued entries and close the connection
+func (this *SyslogLogger) Close() error {
+	err := this.LoggerWriter.Close()
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.closed = true
+	if this.conn != nil {
+		if e := this.conn.Close(); err == nil {
+			err = e
+		}
+		this.conn = nil
+	}
+
+	return err
+}
+
+// Returns the connection, dials without holding mutex if not connected.
+// Other writes wait for the dial in progress and share its result
+func (this *SyslogLogger) connection() (net.Conn, string, error) {
+	this.mutex.Lock()
+
+	if done := this.dialing; done != nil {
+		this.mutex.Unlock()
+		<-done
+		this.mutex.Lock()
+		defer this.mutex.Unlock()
+
+		switch {
+		case this.closed:
+			{
+				return nil, "", errors.New("syslog logger is closed")
+			}
+		case this.conn != nil:
+			{
+				return this.conn, this.connNetwork, nil
+			}
+		}
+
+		return nil, "", this.dialErr
+	}
+
+	switch {
+	case this.closed:
+		{
+			this.mutex.Unlock()
+			return nil, "", errors.New("syslog logger is closed")
+		}
+	case this.conn != nil:
+		{
+			conn, network := this.conn, this.connNetwork
+			this.mutex.Unlock()
+			return conn, network, nil
+		}
+	}
+
+	done := make(chan struct{})
+	this.dialing = done
+	this.mutex.Unlock()
+
+	conn, network, err := this.dial()
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	defer close(done)
+
+	this.dialing = nil
+	this.dialErr = err
+	if err != nil {
+		return nil, "", err
+	}
+
+	if this.closed {
+		conn.Close()
+		return nil, "", errors.New("syslog logger is closed")
+	}
+
+	this.conn, this.connNetwork = conn, network
+
+	return conn, network, nil
+}
+
+// Close conn if it is still the connection
+func (this *SyslogLogger) drop(conn net.Conn) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if this.conn == conn {
+		this.conn = nil
+	}
+	conn.Close()
+}
+
+// Dial the syslog, returns the connection and its network
+func (this *SyslogLogger) dial() (net.Conn, string, error) {
+	if this.network != "" {
+		conn, err := syslogDial(this.network, this.address, syslogDialTimeout)
+		return conn, this.network, err
+	}
+
+	for _, network := range []string{"unixgram", "unix"} {
+		for _, address := range syslogLocalAddresses {
+			conn, err := syslogDial(network, address, syslogDialTimeout)
+			if err == nil {
+				return conn, network, nil
+			}
+		}
+	}
+
+	return nil, "", errors.New("local syslog is unavailable")
+}
+
+// Stream sockets are delimited by newline, datagram sockets are not
+func isStreamNetwork(network string) bool {
+	return !strings.HasPrefix(network, "udp") && network != "unixgram"
+}
+
+func (this *SyslogLogger) message(entry *Entry, stream bool) string {
+	var (
+		priority = this.facility*8 + syslogSeverity(entry.Level)
+		hostname = this.hostname
+		appName  = this.appName
+		message  = strings.TrimRight(entry.Message, "\n")
+	)
+
+	if hostname == "" {
+		hostname = "-"
+	}
+
+	if appName == "" {
+		appName = "-"
+	}
+
+	switch this.format {
+	case SyslogFormatRFC3164:
+		message = fmt.Sprintf("<%d>%s %s %s[%d]: %s", priority, entry.Time.Format(time.Stamp), hostname, appName, os.Getpid(), message)
+	default:
+		message = fmt.Sprintf("<%d>1 %s %s %s %d - - %s", priority, entry.Time.Format(time.RFC3339Nano), hostname, appName, os.Getpid(), message)
+	}
+
+	if stream {
+		message += "\n"
+	}
+
+	return message
+}
+
+// Map LogLevel to syslog severity
+func syslogSeverity(level LogLevel) int {
+	switch level {
+	case FATAL:
+		return 2 // critical
+	case ERROR:
+		return 3 // error
+	case WARN:
+		return 4 // warning
+	case INFO:
+		return 6 // informational
+	default:
+		return 7 // debug
+	}
+}
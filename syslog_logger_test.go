@@ -0,0 +1,287 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package logger
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func readSyslog(t *testing.T, conn net.PacketConn) string {
+	buf := make([]byte, 4096)
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(buf[:n])
+}
+
+func TestSyslogLogger(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	syslogLogger, err := NewSyslogLoggerWithConfig(Logger{
+		Network:  "udp",
+		Address:  server.LocalAddr().String(),
+		Facility: "local0",
+		AppName:  "test",
+		Level:    Level{Allow: "ALL"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer syslogLogger.Close()
+
+	syslogLogger.closeFilter = true
+	syslogLogger.SetSkipCallerDepth(4)
+	syslogLogger.SetFormatter(NewTextFormatterWithFormat("%{Message}"))
+
+	syslogLogger.Info("hello syslog")
+	message := readSyslog(t, server)
+	if !strings.HasPrefix(message, "<134>1 ") || !strings.Contains(message, " test ") || !strings.HasSuffix(message, " - - hello syslog") {
+		t.Errorf("unexpected rfc5424 message %q", message)
+	}
+
+	syslogLogger.SetSyslogFormat(SyslogFormatRFC3164)
+	syslogLogger.Error("hello syslog")
+	message = readSyslog(t, server)
+	if !strings.HasPrefix(message, "<131>") || !strings.HasSuffix(message, "]: hello syslog") {
+		t.Errorf("unexpected rfc3164 message %q", message)
+	}
+
+	for level, severity := range map[LogLevel]int{TRACE: 7, DEBUG: 7, INFO: 6, WARN: 4, ERROR: 3, FATAL: 2} {
+		if syslogSeverity(level) != severity {
+			t.Errorf("unexpected severity %d of %s", syslogSeverity(level), ConvertLevel2String(level))
+		}
+	}
+}
+
+func TestSyslogLoggerReconnect(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syslog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	address := filepath.Join(dir, "syslog.sock")
+
+	server, err := net.ListenPacket("unixgram", address)
+	if err != nil {
+		t.Skip(err)
+	}
+
+	syslogLogger, err := NewSyslogLogger(ALL, "unixgram", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer syslogLogger.Close()
+
+	syslogLogger.closeFilter = true
+	syslogLogger.SetSkipCallerDepth(4)
+	syslogLogger.SetFormatter(NewTextFormatterWithFormat("%{Message}"))
+
+	syslogLogger.Info("before restart")
+	if message := readSyslog(t, server); !strings.HasSuffix(message, "before restart") {
+		t.Errorf("unexpected message %q", message)
+	}
+
+	// restart the syslog server
+	server.Close()
+	os.Remove(address)
+
+	server, err = net.ListenPacket("unixgram", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	err = syslogLogger.Write(INFO, "after restart")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if message := readSyslog(t, server); !strings.HasSuffix(message, "after restart") {
+		t.Errorf("unexpected message %q", message)
+	}
+}
+
+// The logger is created while the syslog is down and connects on a later write
+func TestSyslogLoggerLazyConnect(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	syslogLogger, err := NewSyslogLogger(ALL, "tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer syslogLogger.Close()
+
+	syslogLogger.closeFilter = true
+	syslogLogger.SetFormatter(NewTextFormatterWithFormat("%{Message}"))
+
+	if err = syslogLogger.Write(INFO, "while down"); err == nil {
+		t.Fatal("write succeeded while the syslog is down")
+	}
+
+	listener, err = net.Listen("tcp", address)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer listener.Close()
+
+	if err = syslogLogger.Write(INFO, "after start"); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if line, err := bufio.NewReader(conn).ReadString('\n'); err != nil || !strings.HasSuffix(line, "after start\n") {
+		t.Errorf("unexpected message %q %v", line, err)
+	}
+}
+
+// The writes during a slow dial wait for it instead of being dropped
+func TestSyslogLoggerConcurrentDial(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	var dials int32
+	defer func(dial func(network, address string, timeout time.Duration) (net.Conn, error)) {
+		syslogDial = dial
+	}(syslogDial)
+	syslogDial = func(network, address string, timeout time.Duration) (net.Conn, error) {
+		atomic.AddInt32(&dials, 1)
+		time.Sleep(200 * time.Millisecond)
+		return net.DialTimeout(network, address, timeout)
+	}
+
+	syslogLogger, err := NewSyslogLogger(ALL, "tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer syslogLogger.Close()
+
+	syslogLogger.closeFilter = true
+	syslogLogger.SetFormatter(NewTextFormatterWithFormat("%{Message}"))
+
+	const writers = 20
+
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			if err := syslogLogger.Write(INFO, "concurrent ", i); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(conn)
+	for i := 0; i < writers; i++ {
+		if line, err := reader.ReadString('\n'); err != nil || !strings.Contains(line, "concurrent ") {
+			t.Fatalf("unexpected message %d %q %v", i, line, err)
+		}
+	}
+
+	if n := atomic.LoadInt32(&dials); n != 1 {
+		t.Errorf("unexpected dials %d", n)
+	}
+}
+
+// The local syslog of a stream socket is delimited by newline
+func TestSyslogLoggerLocalStream(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syslog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	address := filepath.Join(dir, "log")
+
+	listener, err := net.Listen("unix", address)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer listener.Close()
+
+	defer func(addresses []string) {
+		syslogLocalAddresses = addresses
+	}(syslogLocalAddresses)
+	syslogLocalAddresses = []string{address}
+
+	syslogLogger, err := NewSyslogLogger(ALL, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer syslogLogger.Close()
+
+	syslogLogger.closeFilter = true
+	syslogLogger.SetFormatter(NewTextFormatterWithFormat("%{Message}"))
+
+	if err = syslogLogger.Write(INFO, "first"); err != nil {
+		t.Fatal(err)
+	}
+	if err = syslogLogger.Write(INFO, "second"); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(conn)
+	for _, expected := range []string{"first\n", "second\n"} {
+		if line, err := reader.ReadString('\n'); err != nil || !strings.HasSuffix(line, expected) {
+			t.Errorf("unexpected message %q %v", line, err)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+// Package sqlite tests the DATABASE target against sqlite,
+// it is a separate module so the cgo driver is not a dependency of the logger
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ronzxy/go-logger"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestDatabaseLogger(t *testing.T) {
+	dir, err := ioutil.TempDir("", "database")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	dataSource := filepath.Join(dir, "logs.db")
+
+	c, err := logger.NewConfigBuilder().
+		DatabaseLogger("database", "sqlite3", dataSource, "app_logs").
+		Level("ALL", "").
+		Format("text", "%{Message}").
+		Batch("", 2, 0).
+		Retention(7).
+		DefaultFilter("database").
+		PackageFilter("github.com/ronzxy/go-logger/test/sqlite", "database").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := logger.InitWithConfig(c); err != nil {
+		t.Fatal(err)
+	}
+
+	logger.Info("first")
+	logger.WithFields(map[string]interface{}{"user": "ron"}).Warn("second")
+	logger.Error("third")
+
+	databaseLogger := logger.GetWriter("database").(*logger.DatabaseLogger)
+	err = databaseLogger.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite3", dataSource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// an entry older than the retention days
+	_, err = db.Exec("INSERT INTO app_logs (log_time, level, message) VALUES (?, ?, ?)", time.Now().UTC().AddDate(0, 0, -30), "INFO", "expired")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	databaseLogger.Purge()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := logger.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := db.Query("SELECT level, file_name, message, fields FROM app_logs ORDER BY log_time")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var results []string
+	for rows.Next() {
+		var (
+			level, fileName, message string
+			fields                   sql.NullString
+		)
+
+		err = rows.Scan(&level, &fileName, &message, &fields)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if fileName != "database_logger_test.go" {
+			t.Errorf("unexpected file name %s", fileName)
+		}
+		results = append(results, level+" "+message+" "+fields.String)
+	}
+
+	if len(results) != 3 || results[0] != "INFO first " || results[1] != `WARN second {"user":"ron"}` || results[2] != "ERROR third " {
+		t.Errorf("unexpected rows %q", results)
+	}
+}
@@ -0,0 +1,10 @@
+module github.com/ronzxy/go-logger/test/sqlite
+
+go 1.13
+
+require (
+	github.com/mattn/go-sqlite3 v1.14.6
+	github.com/ronzxy/go-logger v0.0.0
+)
+
+replace github.com/ronzxy/go-logger => ../..
@@ -1,110 +1,526 @@
 package logger
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/ronzxy/go-helper"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 )
 
+// TextFormatter formats the entries by a pattern of placeholders such as %{Level:5}.
+// The last argument of a placeholder may be a width and a precision, the same for every placeholder:
+// a bare width or a width with - pads on the right as the widths of Level, File and Line always did,
+// a width with + pads on the left
 type TextFormatter struct {
 	Format string
+
+	compiled  atomic.Value // *textLayout of Format
+	colorLock sync.Mutex
+	colorMode string       // ColorAuto, ColorAlways or ColorNever
+	output    io.Writer    // the output of the logger using the formatter, checked in ColorAuto mode
+	colored   int32        // render %{Color} and %{ColorReset}
+	colors    atomic.Value // *levelColors, replaced by SetLevelColor
+}
+
+// The escape sequences of %{Color} indexed by level
+type levelColors [OFF + 1]string
+
+// The kinds of textToken
+const (
+	textLiteral = iota
+	textPrefix
+	textTime
+	textUTCTime
+	textElapsed
+	textLevel
+	textFile
+	textLine
+	textFunc
+	textShortFunc
+	textPackage
+	textPID
+	textHostname
+	textGoroutineID
+	textEnv
+	textMessage
+	textCtx
+	textField
+	textFields
+	textColor
+	textColorReset
+)
+
+// A part of the compiled format, the literal text or a placeholder
+type textToken struct {
+	kind int
+	text string // the literal text, the time layout or the name of the Env variable, Ctx value or Field
+
+	width       int  // the minimum width, padded on the right
+	rightAlign  bool // padded on the left
+	precision   int  // the maximum width, truncated from the beginning
+	truncateEnd bool // truncated from the end
+}
+
+// The tokens parsed from a format
+type textLayout struct {
+	format      string
+	tokens      []textToken
+	unsupported []string // reported once by Message
+	report      sync.Once
 }
 
 var (
 	DefaultFormat = "%{Prefix} - %{Time:yyyy-mm-dd HH:MM:SS.ms} - %{Level:5} - %{File}:%{Line:3} - %{Message}"
+
+	// the default format of the STDOUT target, the message is colored by level
+	DefaultConsoleFormat = "%{Prefix} - %{Time:yyyy-mm-dd HH:MM:SS.ms} - %{Level:5} - %{File}:%{Line:3} - %{Color}%{Message}%{ColorReset}"
+
+	// placeholders supported by Message, used by Config.Validate
+	textFormatPlaceholders = []string{
+		"Prefix", "Time", "UTCTime", "Elapsed", "Level", "File", "Line", "Func", "ShortFunc", "Package",
+		"PID", "Hostname", "GoroutineID", "Env", "Message", "Ctx", "Field", "Fields", "Color", "ColorReset",
+	}
+
+	textTokenKinds = map[string]int{
+		"PREFIX":      textPrefix,
+		"TIME":        textTime,
+		"UTCTIME":     textUTCTime,
+		"ELAPSED":     textElapsed,
+		"LEVEL":       textLevel,
+		"FILE":        textFile,
+		"LINE":        textLine,
+		"FUNC":        textFunc,
+		"SHORTFUNC":   textShortFunc,
+		"PACKAGE":     textPackage,
+		"PID":         textPID,
+		"HOSTNAME":    textHostname,
+		"GOROUTINEID": textGoroutineID,
+		"ENV":         textEnv,
+		"MESSAGE":     textMessage,
+		"CTX":         textCtx,
+		"FIELD":       textField,
+		"FIELDS":      textFields,
+		"COLOR":       textColor,
+		"COLORRESET":  textColorReset,
+	}
+
+	// width and precision such as -30.30, the last argument of a placeholder
+	textModifierRegexp = regexp.MustCompile(`^([-+]?)(\d*)(?:\.(-?)(\d+))?$`)
+
+	// The reference time of the Go layouts, it is formatted into the layout itself
+	layoutReferenceTime = time.Date(2006, time.January, 2, 15, 4, 5, 0, time.FixedZone("MST", -7*60*60))
+
+	processStartTime = time.Now()
+	pid              = strconv.Itoa(os.Getpid())
+	hostname, _      = os.Hostname()
+
+	// set when a format contains %{GoroutineID}, the writers only capture the id then
+	goroutineIDUsed int32
 )
 
 func NewTextFormatter() *TextFormatter {
-	return &TextFormatter{
-		Format: DefaultFormat,
-	}
+	return newTextFormatter(DefaultFormat)
 }
 
 func NewTextFormatterWithFormat(format string) *TextFormatter {
-	this := NewTextFormatter()
-	this.Format = VariableReplaceByConfig(format)
+	return newTextFormatter(VariableReplaceByConfig(format))
+}
+
+// Returns the TextFormatter of the format and colors of v,
+// the default format of the target is used if the format is empty
+func NewTextFormatterWithConfig(v Logger) *TextFormatter {
+	format := VariableReplaceByConfig(v.Format.Value)
+	if format == "" {
+		format = DefaultFormat
+		if v.Target == "STDOUT" {
+			format = DefaultConsoleFormat
+		}
+	}
+
+	this := newTextFormatter(format)
+	this.SetColorMode(v.Format.Color)
+
+	for _, color := range v.Colors {
+		if err := this.SetLevelColor(ConvertString2Level(color.Level), color.Color, color.Bold); err != nil {
+			DefaultConsoleLogger().Errorf("logger %s: %s", v.Name, err.Error())
+		}
+	}
 
 	return this
 }
 
+// The colors are rendered in ColorAuto mode with the default level colors
+func newTextFormatter(format string) *TextFormatter {
+	this := &TextFormatter{}
+	this.SetFormat(format)
+	this.SetColorMode(ColorAuto)
+
+	colors := &levelColors{}
+	for level, color := range defaultLevelColors {
+		colors[level], _ = colorSequence(color, false)
+	}
+	this.colors.Store(colors)
+
+	return this
+}
+
+// Set the format and parse it into tokens
 func (this *TextFormatter) SetFormat(format string) {
 	this.Format = format
+	this.compiled.Store(compileTextFormat(format))
+}
+
+// Set whether %{Color} and %{ColorReset} are rendered, mode is ColorAuto, ColorAlways or ColorNever.
+// In ColorAuto mode, the default, they are rendered only if the output of the logger is a terminal
+// and NO_COLOR is not set
+func (this *TextFormatter) SetColorMode(mode string) {
+	this.colorLock.Lock()
+	defer this.colorLock.Unlock()
+
+	this.colorMode = mode
+	this.updateColored()
+}
+
+// Set the output checked in ColorAuto mode, called when the formatter or the output of a logger is set
+func (this *TextFormatter) setOutput(w io.Writer) {
+	this.colorLock.Lock()
+	defer this.colorLock.Unlock()
+
+	this.output = w
+	this.updateColored()
 }
 
-func (this *TextFormatter) Message(data map[string]interface{}, args ...interface{}) string {
+func (this *TextFormatter) updateColored() {
+	var colored int32
+	if colorEnabled(this.colorMode, this.output) {
+		colored = 1
+	}
+
+	atomic.StoreInt32(&this.colored, colored)
+}
+
+// Set the style of %{Color} for level, color is empty or one of black, red, green, yellow, blue, magenta, cyan, white and gray
+func (this *TextFormatter) SetLevelColor(level LogLevel, color string, bold bool) error {
+	if level < ALL || level > OFF {
+		return fmt.Errorf("unknown level %d", level)
+	}
+
+	sequence, err := colorSequence(color, bold)
+	if err != nil {
+		return err
+	}
+
+	this.colorLock.Lock()
+	defer this.colorLock.Unlock()
+
+	// copied on write, the entries being formatted keep the previous colors
+	colors := &levelColors{}
+	if current, ok := this.colors.Load().(*levelColors); ok {
+		*colors = *current
+	}
+	colors[level] = sequence
+	this.colors.Store(colors)
+
+	return nil
+}
+
+// Returns the color of level, empty if not colored
+func (this *TextFormatter) levelColor(level LogLevel) string {
+	colors, ok := this.colors.Load().(*levelColors)
+	if !ok || atomic.LoadInt32(&this.colored) == 0 || level < ALL || level > OFF {
+		return ""
+	}
+
+	return colors[level]
+}
+
+// Returns the tokens of Format, parsed again if Format is assigned directly
+func (this *TextFormatter) layout() *textLayout {
+	if layout, ok := this.compiled.Load().(*textLayout); ok && layout.format == this.Format {
+		return layout
+	}
+
+	layout := compileTextFormat(this.Format)
+	this.compiled.Store(layout)
+
+	return layout
+}
+
+func compileTextFormat(format string) *textLayout {
 	var (
-		varPattern string
-		varName    string
-		vars       = make([]string, 2)
-		str        = this.Format
+		layout = &textLayout{format: format}
+		start  = 0
 	)
-	for {
-		varPattern, varName = Variable("%", "([a-zA-Z_][0-9a-zA-Z\\s\\._/:-]*)", str)
-		if varName == "" {
-			// no variable, exit loop
-			break
+
+	for _, match := range textFormatRegexp.FindAllStringSubmatchIndex(format, -1) {
+		if match[0] > start {
+			layout.tokens = append(layout.tokens, textToken{text: format[start:match[0]]})
 		}
 
-		if strings.Contains(varName, ":") {
-			vars = strings.Split(varName, ":")
-		} else {
-			vars[0] = varName
-			vars[1] = ""
+		token, ok := newTextToken(format[match[2]:match[3]])
+		if !ok {
+			layout.unsupported = append(layout.unsupported, token.text)
+		}
+
+		if token.kind == textGoroutineID {
+			atomic.StoreInt32(&goroutineIDUsed, 1)
+		}
+
+		layout.tokens = append(layout.tokens, token)
+		start = match[1]
+	}
+
+	if start < len(format) {
+		layout.tokens = append(layout.tokens, textToken{text: format[start:]})
+	}
+
+	return layout
+}
+
+// Parse a placeholder such as Level:5, File:+30.30 or Time:yyyy-mm-dd HH:MM:SS:30,
+// an unsupported placeholder is kept as the literal name
+func newTextToken(name string) (textToken, bool) {
+	vars := strings.Split(name, ":")
+
+	kind, ok := textTokenKinds[strings.ToUpper(vars[0])]
+	if !ok {
+		return textToken{text: name}, false
+	}
+
+	token := textToken{kind: kind}
+
+	// the modifier is the last argument
+	if args := vars[1:]; len(args) > 0 {
+		if match := textModifierRegexp.FindStringSubmatch(args[len(args)-1]); match != nil && (match[2] != "" || match[4] != "") {
+			token.rightAlign = match[1] == "+"
+			token.width, _ = strconv.Atoi(match[2])
+			token.truncateEnd = match[3] == "-"
+			token.precision, _ = strconv.Atoi(match[4])
+
+			vars = vars[:len(vars)-1]
+		}
+	}
+
+	arg := strings.Join(vars[1:], ":")
+
+	switch kind {
+	case textTime, textUTCTime:
+		{
+			token.text = DefaultLogTimeFormat
+			if arg != "" {
+				token.text = timeLayout(arg)
+			}
+		}
+	case textEnv, textCtx, textField:
+		{
+			token.text = arg
+		}
+	case textColor, textColorReset:
+		{
+			// the escape sequences are not aligned
+			token.width, token.precision = 0, 0
 		}
+	}
+
+	return token, true
+}
+
+// Convert the layout of helper.Time.Format, such as yyyy-mm-dd HH:MM:SS.ms, to the Go layout
+func timeLayout(layout string) string {
+	return helper.Time.Format(layout, layoutReferenceTime)
+}
+
+func (this *TextFormatter) Message(record *Record) string {
+	if record.buffer == nil {
+		record.buffer = &bytes.Buffer{}
+	}
 
-		switch strings.ToUpper(vars[0]) {
-		case "PREFIX":
+	var (
+		layout  = this.layout()
+		buffer  = record.buffer
+		color   = this.levelColor(record.Level)
+		scratch [64]byte
+	)
+
+	if len(layout.unsupported) > 0 {
+		layout.report.Do(func() {
+			for _, name := range layout.unsupported {
+				DefaultConsoleLogger().Errorf("unsupported log format %s", name)
+			}
+		})
+	}
+
+	t := record.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+
+	buffer.Reset()
+	for _, token := range layout.tokens {
+		start := buffer.Len()
+
+		switch token.kind {
+		case textLiteral:
 			{
-				varName = fmt.Sprintf("%v", data["Prefix"])
+				buffer.WriteString(token.text)
 			}
-		case "TIME":
+		case textEnv:
 			{
-				if vars[1] != "" {
-					varName = helper.Time.Format(strings.Join(vars[1:], ":"), time.Now())
-				} else {
-					varName = time.Now().Format(DefaultLogTimeFormat)
-				}
+				// read by every entry, so the changes of the environment are shown
+				buffer.WriteString(os.Getenv(token.text))
+			}
+		case textPrefix:
+			{
+				buffer.WriteString(record.Prefix)
+			}
+		case textTime:
+			{
+				buffer.Write(t.AppendFormat(scratch[:0], token.text))
+			}
+		case textUTCTime:
+			{
+				buffer.Write(t.UTC().AppendFormat(scratch[:0], token.text))
+			}
+		case textElapsed:
+			{
+				buffer.Write(strconv.AppendInt(scratch[:0], int64(t.Sub(processStartTime)/time.Millisecond), 10))
+			}
+		case textLevel:
+			{
+				buffer.WriteString(ConvertLevel2String(record.Level))
+			}
+		case textFile:
+			{
+				buffer.WriteString(record.File)
+			}
+		case textLine:
+			{
+				buffer.Write(strconv.AppendInt(scratch[:0], int64(record.Line), 10))
 			}
-		case "LEVEL":
+		case textFunc:
 			{
-				format := "%s"
-				if vars[1] != "" {
-					format = fmt.Sprintf("%%-%ss", vars[1])
+				buffer.WriteString(record.Function)
+			}
+		case textShortFunc:
+			{
+				buffer.WriteString(record.Function[strings.LastIndex(record.Function, ".")+1:])
+			}
+		case textPackage:
+			{
+				buffer.WriteString(record.PackageName)
+			}
+		case textPID:
+			{
+				buffer.WriteString(pid)
+			}
+		case textHostname:
+			{
+				buffer.WriteString(hostname)
+			}
+		case textGoroutineID:
+			{
+				buffer.Write(strconv.AppendUint(scratch[:0], record.GoroutineID, 10))
+			}
+		case textMessage:
+			{
+				if len(record.Args) == 1 {
+					if message, ok := record.Args[0].(string); ok {
+						buffer.WriteString(message)
+						break
+					}
 				}
 
-				varName = fmt.Sprintf(format, data["Level"])
+				fmt.Fprint(buffer, record.Args...)
 			}
-		case "FILE":
+		case textCtx:
 			{
-				format := "%s"
-				if vars[1] != "" {
-					format = fmt.Sprintf("%%-%ss", vars[1])
+				if token.text == "" {
+					buffer.WriteString(Fields2String(record.Ctx))
+				} else if value, ok := record.Ctx[token.text]; ok {
+					fmt.Fprintf(buffer, "%v", value)
 				}
-
-				varName = fmt.Sprintf(format, data["File"])
 			}
-		case "LINE":
+		case textField:
 			{
-				format := "%s"
-				if vars[1] != "" {
-					format = fmt.Sprintf("%%-%sd", vars[1])
+				if value, ok := record.Fields[token.text]; ok {
+					fmt.Fprintf(buffer, "%v", value)
 				}
-
-				varName = fmt.Sprintf(format, data["Line"])
 			}
-		case "MESSAGE":
+		case textFields:
 			{
-				varName = fmt.Sprint(args...)
+				buffer.WriteString(Fields2String(record.Fields))
 			}
-		default:
+		case textColor:
 			{
-				DefaultConsoleLogger().Errorf("unsupported log format %s", varName)
+				buffer.WriteString(color)
 			}
+		case textColorReset:
+			{
+				if color != "" {
+					buffer.WriteString(colorReset)
+				}
+			}
+		}
+
+		if token.width > 0 || token.precision > 0 {
+			token.align(buffer, start)
 		}
+	}
+
+	return buffer.String()
+}
+
+// Truncate to the precision and pad to the width the value written from start
+func (this *textToken) align(buffer *bytes.Buffer, start int) {
+	var (
+		value = buffer.Bytes()[start:]
+		count = utf8.RuneCount(value)
+	)
+
+	if this.precision > 0 && count > this.precision {
+		if this.truncateEnd {
+			value = value[:runeOffset(value, this.precision)]
+		} else {
+			value = value[runeOffset(value, count-this.precision):]
+		}
+
+		buffer.Truncate(start + copy(buffer.Bytes()[start:], value))
+		count = this.precision
+	}
+
+	padding := this.width - count
+	if padding <= 0 {
+		return
+	}
+
+	for i := 0; i < padding; i++ {
+		buffer.WriteByte(' ')
+	}
+
+	if this.rightAlign {
+		b := buffer.Bytes()
+		copy(b[start+padding:], b[start:len(b)-padding])
+		for i := start; i < start+padding; i++ {
+			b[i] = ' '
+		}
+	}
+}
 
-		str = strings.Replace(str, varPattern, varName, -1)
+// Returns the offset of the n-th rune of b
+func runeOffset(b []byte, n int) int {
+	offset := 0
+	for i := 0; i < n && offset < len(b); i++ {
+		_, size := utf8.DecodeRune(b[offset:])
+		offset += size
 	}
 
-	return str
+	return offset
 }
@@ -0,0 +1,306 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package logger
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTextFormatter(t *testing.T) {
+	var (
+		formatter = NewTextFormatterWithFormat("[%{Time:yyyy-mm-dd HH:MM:SS.ms}] %{Level:5}|%{File}:%{Line:4}|%{Ctx:id}|%{Message}")
+		record    = newRecord(&Entry{
+			Time:  time.Date(2019, time.October, 13, 4, 12, 35, 123000000, time.UTC),
+			Level: WARN,
+			File:  "app/main.go",
+			Line:  42,
+			Ctx:   map[string]interface{}{"id": 7},
+			Args:  []interface{}{"disk %{Level}", 90},
+		})
+	)
+	defer record.release()
+
+	if message := formatter.Message(record); message != "[2019-10-13 04:12:35.123] WARN |app/main.go:42  |7|disk %{Level}90" {
+		t.Errorf("unexpected message %s", message)
+	}
+
+	// compiled again when Format is assigned directly
+	formatter.Format = "%{Level} %{Message}"
+	if message := formatter.Message(record); message != "WARN disk %{Level}90" {
+		t.Errorf("unexpected message %s", message)
+	}
+}
+
+func TestTextFormatterPlaceholders(t *testing.T) {
+	os.Setenv("LOGGER_TEST_ZONE", "east")
+	defer os.Unsetenv("LOGGER_TEST_ZONE")
+
+	var (
+		zone   = time.FixedZone("CST", 8*60*60)
+		record = newRecord(&Entry{
+			Time:        time.Date(2019, time.October, 13, 12, 0, 0, 0, zone),
+			Level:       ERROR,
+			PackageName: "github.com/us/app/db",
+			Function:    "github.com/us/app/db.(*Pool).Get",
+			File:        "github.com/us/app/db/pool.go",
+			Line:        7,
+			GoroutineID: 18,
+			Fields:      map[string]interface{}{"user": "ron"},
+			Args:        []interface{}{"timeout"},
+		})
+	)
+	defer record.release()
+
+	for format, expected := range map[string]string{
+		"%{Func} %{ShortFunc} %{Package}":                      "github.com/us/app/db.(*Pool).Get Get github.com/us/app/db",
+		"%{PID} %{Hostname} %{GoroutineID}":                    strconv.Itoa(os.Getpid()) + " " + hostname + " 18",
+		"%{UTCTime:HH:MM} %{Time:HH:MM}":                       "04:00 12:00",
+		"%{Env:LOGGER_TEST_ZONE} %{Field:user}|%{Field:none}|": "east ron||",
+		"[%{File:-30.30}]":                                     "[github.com/us/app/db/pool.go  ]",
+		"[%{File:12}]":                                         "[github.com/us/app/db/pool.go]",
+		"[%{File:.7}]":                                         "[pool.go]",
+		"[%{File:.-10}]":                                       "[github.com]",
+		"[%{Level:+7}][%{Line:-3}][%{Message:3.4}]":            "[  ERROR][7  ][eout]",
+		"[%{Time:HH:MM:SS:+10}]":                               "[  12:00:00]",
+		"[%{Field:user:-5}]":                                   "[ron  ]",
+	} {
+		if message := NewTextFormatterWithFormat(format).Message(record); message != expected {
+			t.Errorf("unexpected message of %s: %s", format, message)
+		}
+	}
+
+	if elapsed, err := strconv.Atoi(NewTextFormatterWithFormat("%{Elapsed}").Message(newRecord(&Entry{}))); err != nil || elapsed < 0 {
+		t.Errorf("unexpected elapsed %d %v", elapsed, err)
+	}
+}
+
+// A bare width pads on the right for every placeholder, as the widths of Level, File and Line always did
+func TestTextFormatterBareWidthPadsRight(t *testing.T) {
+	os.Setenv("LOGGER_TEST_ZONE", "east")
+	defer os.Unsetenv("LOGGER_TEST_ZONE")
+
+	record := newRecord(&Entry{
+		Level:    INFO,
+		Function: "app.Run",
+		File:     "main.go",
+		Line:     7,
+		Fields:   map[string]interface{}{"user": "ron"},
+		Args:     []interface{}{"ok"},
+	})
+	defer record.release()
+
+	formatter := NewTextFormatterWithFormat("[%{Level:5}][%{Line:3}][%{File:8}][%{ShortFunc:4}][%{Env:LOGGER_TEST_ZONE:5}][%{Field:user:4}][%{Message:3}]")
+	if message := formatter.Message(record); message != "[INFO ][7  ][main.go ][Run ][east ][ron ][ok ]" {
+		t.Errorf("unexpected bare widths %q", message)
+	}
+
+	formatter = NewTextFormatterWithFormat("[%{Level:+5}][%{Line:+3}][%{Env:LOGGER_TEST_ZONE:+5}][%{Message:-3}]")
+	if message := formatter.Message(record); message != "[ INFO][  7][ east][ok ]" {
+		t.Errorf("unexpected signed widths %q", message)
+	}
+
+	// Env is read by every entry
+	os.Setenv("LOGGER_TEST_ZONE", "west")
+	if message := formatter.Message(record); message != "[ INFO][  7][ west][ok ]" {
+		t.Errorf("unexpected changed env %q", message)
+	}
+}
+
+// The goroutine id is captured by the writers once a format contains %{GoroutineID}
+func TestGoroutineID(t *testing.T) {
+	var buf bytes.Buffer
+
+	writer := NewLoggerWriter(&buf, ALL)
+	writer.closeFilter = true
+	writer.SetSkipCallerDepth(4)
+	writer.SetFormatter(NewTextFormatterWithFormat("%{GoroutineID} %{ShortFunc}"))
+
+	writer.Info("goroutine")
+
+	fields := strings.Fields(buf.String())
+	if len(fields) != 2 || fields[1] != "TestGoroutineID" {
+		t.Fatalf("unexpected line %s", buf.String())
+	}
+
+	if id, err := strconv.ParseUint(fields[0], 10, 64); err != nil || id == 0 {
+		t.Errorf("unexpected goroutine id %s", fields[0])
+	}
+}
+
+func TestTextFormatterColor(t *testing.T) {
+	os.Setenv("NO_COLOR", "1")
+	defer os.Unsetenv("NO_COLOR")
+
+	var (
+		formatter = NewTextFormatterWithFormat("%{Color}%{Level:-5}%{ColorReset}|%{Message}")
+		info      = newRecord(&Entry{Level: INFO, Args: []interface{}{"info"}})
+		err       = newRecord(&Entry{Level: ERROR, Args: []interface{}{"error"}})
+	)
+	defer info.release()
+	defer err.release()
+
+	// disabled by NO_COLOR in auto mode
+	if message := formatter.Message(info); message != "INFO |info" {
+		t.Errorf("unexpected message %q", message)
+	}
+
+	formatter.SetColorMode(ColorAlways)
+	if e := formatter.SetLevelColor(ERROR, "red", true); e != nil {
+		t.Fatal(e)
+	}
+
+	if message := formatter.Message(info); message != "\033[36mINFO \033[0m|info" {
+		t.Errorf("unexpected message %q", message)
+	}
+
+	if message := formatter.Message(err); message != "\033[1;31mERROR\033[0m|error" {
+		t.Errorf("unexpected message %q", message)
+	}
+
+	if e := formatter.SetLevelColor(WARN, "orange", false); e == nil {
+		t.Error("unknown color is accepted")
+	}
+
+	formatter.SetColorMode(ColorNever)
+	if message := formatter.Message(err); message != "ERROR|error" {
+		t.Errorf("unexpected message %q", message)
+	}
+}
+
+// The Printf path of ConsoleLogger is colored by the formatter too
+func TestConsoleLoggerColor(t *testing.T) {
+	var buf bytes.Buffer
+
+	c, err := NewConfigBuilder().
+		ConsoleLogger("console").
+		Format("text", "%{Color}%{Message}%{ColorReset}").
+		Color(ColorAlways, Color{Level: "ALL", Color: "gray"}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	consoleLogger := NewConsoleLogger(ALL)
+	consoleLogger.closeFilter = true
+	consoleLogger.SetWriter(&buf)
+	consoleLogger.SetFormatter(NewTextFormatterWithConfig(c.Loggers[0]))
+
+	consoleLogger.Printf("printf %d", 1)
+	consoleLogger.Warn("warn")
+
+	if result := buf.String(); result != "\033[90mprintf 1\033[0m\n\033[35mwarn\033[0m\n" {
+		t.Errorf("unexpected output %q", result)
+	}
+
+	// ALL is styled by default
+	buf.Reset()
+	formatter := NewTextFormatterWithFormat("%{Color}%{Message}%{ColorReset}")
+	formatter.SetColorMode(ColorAlways)
+	consoleLogger.SetFormatter(formatter)
+	consoleLogger.Print("print")
+
+	if result := buf.String(); result != "\033[37mprint\033[0m\n" {
+		t.Errorf("unexpected default output %q", result)
+	}
+
+	// in auto mode the colors are decided by the output, a buffer or a file is not a terminal
+	file, err := ioutil.TempFile("", "console")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	buf.Reset()
+	formatter.SetColorMode(ColorAuto)
+	consoleLogger.Print("auto")
+	consoleLogger.SetWriter(file)
+	consoleLogger.Print("file")
+
+	if result := buf.String(); result != "auto\n" {
+		t.Errorf("unexpected auto output %q", result)
+	}
+
+	if content, _ := ioutil.ReadFile(file.Name()); string(content) != "file\n" {
+		t.Errorf("unexpected file content %q", content)
+	}
+
+	_, err = NewConfigBuilder().
+		ConsoleLogger("console").
+		Color("sometimes", Color{Level: "VERBOSE", Color: "orange"}).
+		Build()
+	if err == nil || !strings.Contains(err.Error(), `unknown color mode "sometimes"`) || !strings.Contains(err.Error(), `unknown color "orange"`) || !strings.Contains(err.Error(), `unknown level "VERBOSE"`) {
+		t.Errorf("unexpected build error: %v", err)
+	}
+}
+
+// The level colors are changed while logging, run with -race
+func TestTextFormatterSetLevelColorConcurrent(t *testing.T) {
+	var buf lockedBuffer
+
+	formatter := NewTextFormatterWithFormat("%{Color}%{Message}%{ColorReset}")
+	formatter.SetColorMode(ColorAlways)
+
+	writer := NewLoggerWriter(&buf, ALL)
+	writer.closeFilter = true
+	writer.SetFormatter(formatter)
+	writer.Error("message")
+
+	var (
+		wg   sync.WaitGroup
+		done = make(chan struct{})
+	)
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					writer.Error("message")
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 100; i++ {
+		color := "red"
+		if i%2 == 1 {
+			color = "blue"
+		}
+
+		if err := formatter.SetLevelColor(ERROR, color, false); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(100 * time.Microsecond)
+	}
+	close(done)
+	wg.Wait()
+
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line != "\033[31mmessage\033[0m" && line != "\033[34mmessage\033[0m" && line != "\033[33mmessage\033[0m" {
+			t.Fatalf("unexpected line %q", line)
+		}
+	}
+}
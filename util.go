@@ -13,6 +13,7 @@
 package logger
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/ronzxy/go-helper"
 	"os"
@@ -20,6 +21,7 @@ import (
 	"regexp"
 	"runtime"
 	"strings"
+	"sync/atomic"
 )
 
 // Logger level
@@ -113,19 +115,13 @@ func Variable(varchar, pattern, str string) (string, string) {
 	return varPattern, varName
 }
 
-// Replace the variable define in properties
+// Replace the variable define in properties,
+// ${env:NAME} is replaced by environment variable and ${NAME:-default} by the default value if undefined
 func VariableReplaceByConfig(str string) string {
-	for {
-		varPattern, varName := Variable("$", "([a-zA-Z_][0-9a-zA-Z_]+)", str)
-		if varName == "" {
-			// 没有发现变量，退出循环
-			break
-		}
-
-		varName = RemoveEnterAndSpace(propertyMap[varName])
-
-		str = strings.Replace(str, varPattern, varName, -1)
-	}
+	str = expandProperties(str, func(name string) (string, bool) {
+		value, ok := loadState().properties[name]
+		return value, ok
+	})
 
 	str = RemoveEnterAndSpace(str)
 
@@ -150,6 +146,29 @@ func GetCaller(skip int) *runtime.Frame {
 	return &frame
 }
 
+// Returns the id of the current goroutine if %{GoroutineID} is used by any TextFormatter, otherwise 0
+func captureGoroutineID() uint64 {
+	if atomic.LoadInt32(&goroutineIDUsed) == 0 {
+		return 0
+	}
+
+	// the stack starts with "goroutine 18 [running]:"
+	var (
+		buf [64]byte
+		id  uint64
+	)
+
+	for _, c := range bytes.TrimPrefix(buf[:runtime.Stack(buf[:], false)], []byte("goroutine ")) {
+		if c < '0' || c > '9' {
+			break
+		}
+
+		id = id*10 + uint64(c-'0')
+	}
+
+	return id
+}
+
 // Get the package name by runtime.Frame.Function
 func GetPackageName(f string) string {
 	for {
@@ -13,6 +13,8 @@
 package logger
 
 import (
+	"context"
+
 	xormlog "github.com/ronzxy/go-xorm/log"
 )
 
@@ -42,8 +44,50 @@ type Writer interface {
 
 	FatalWithExit(exit bool, args ...interface{})
 
+	TracefCtx(ctx context.Context, format string, args ...interface{})
+
+	DebugfCtx(ctx context.Context, format string, args ...interface{})
+
+	InfofCtx(ctx context.Context, format string, args ...interface{})
+
+	WarnfCtx(ctx context.Context, format string, args ...interface{})
+
+	ErrorfCtx(ctx context.Context, format string, args ...interface{})
+
+	FatalfCtxWithExit(ctx context.Context, exit bool, format string, args ...interface{})
+
+	TraceCtx(ctx context.Context, args ...interface{})
+
+	DebugCtx(ctx context.Context, args ...interface{})
+
+	InfoCtx(ctx context.Context, args ...interface{})
+
+	WarnCtx(ctx context.Context, args ...interface{})
+
+	ErrorCtx(ctx context.Context, args ...interface{})
+
+	FatalCtxWithExit(ctx context.Context, exit bool, args ...interface{})
+
 	CheckRollingSize()
 
+	Enabled(level LogLevel) bool
+
+	SetLevels(allow, deny LogLevel)
+
+	Levels() (LogLevel, LogLevel)
+
+	Append(entry *Entry) error
+
+	Emit(entry *Entry) error
+
+	Flush() error
+
+	Close() error
+
+	WithFields(fields map[string]interface{}) Writer
+
+	With(keyValues ...interface{}) Writer
+
 	/*
 	   Include xorm logger
 	*/